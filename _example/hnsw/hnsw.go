@@ -64,8 +64,12 @@ func main() {
 		fmt.Println()
 	}
 
-	// search xq
+	// search xq, exploring a larger candidate list than the default for
+	// better recall
 
+	if err := index.SetEfSearch(64); err != nil {
+		log.Fatal(err)
+	}
 	_, ids, err = index.Search(query_vecs, k)
 	if err != nil {
 		log.Fatal(err)
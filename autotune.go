@@ -6,11 +6,16 @@ package faiss
 */
 import "C"
 import (
+	"runtime"
 	"unsafe"
 )
 
 type ParameterSpace struct {
 	ps *C.FaissParameterSpace
+
+	// cleanup frees ps if the parameter space is garbage collected without
+	// Delete.
+	cleanup runtime.Cleanup
 }
 
 // NewParameterSpace creates a new ParameterSpace.
@@ -19,11 +24,25 @@ func NewParameterSpace() (*ParameterSpace, error) {
 	if c := C.faiss_ParameterSpace_new(&ps); c != 0 {
 		return nil, newFaissError(ErrCreateParamsFailed, getLastError(), int(c))
 	}
-	return &ParameterSpace{ps}, nil
+	p := &ParameterSpace{ps: ps}
+	p.cleanup = addLeakCleanup(p, "faiss parameter space", func() {
+		C.faiss_ParameterSpace_free(ps)
+	})
+	return p, nil
 }
 
 // SetIndexParameter sets one of the parameters.
 func (p *ParameterSpace) SetIndexParameter(idx Index, name string, val float64) error {
+	if p.ps == nil {
+		return ErrParamsClosed
+	}
+	idxPtr := idx.cPtr()
+	if idxPtr == nil {
+		return ErrIndexClosed
+	}
+	defer runtime.KeepAlive(p)
+	defer runtime.KeepAlive(idx)
+
 	cname := C.CString(name)
 
 	defer func() {
@@ -31,14 +50,20 @@ func (p *ParameterSpace) SetIndexParameter(idx Index, name string, val float64)
 	}()
 
 	c := C.faiss_ParameterSpace_set_index_parameter(
-		p.ps, idx.cPtr(), cname, C.double(val))
+		p.ps, idxPtr, cname, C.double(val))
 	if c != 0 {
 		return newFaissError(ErrSetParamsFailed, getLastError(), int(c))
 	}
 	return nil
 }
 
-// Delete frees the memory associated with p.
+// Delete frees the memory associated with p. It is safe to call Delete more
+// than once.
 func (p *ParameterSpace) Delete() {
+	if p.ps == nil {
+		return
+	}
+	p.cleanup.Stop()
 	C.faiss_ParameterSpace_free(p.ps)
+	p.ps = nil
 }
@@ -0,0 +1,145 @@
+// Typed binary indexes, see index_binary_types.go.
+
+#include "gofaiss.h"
+#include "gofaiss_impl.h"
+
+#include <faiss/IndexBinaryFlat.h>
+#include <faiss/IndexBinaryHNSW.h>
+#include <faiss/IndexBinaryHash.h>
+#include <faiss/IndexBinaryIVF.h>
+#include <faiss/IndexIDMap.h>
+
+namespace {
+
+faiss::IndexBinary* binary_index(FaissIndexBinary* index) {
+    return reinterpret_cast<faiss::IndexBinary*>(index);
+}
+
+const faiss::IndexBinary* binary_index(const FaissIndexBinary* index) {
+    return reinterpret_cast<const faiss::IndexBinary*>(index);
+}
+
+const faiss::IndexBinaryHNSW* bhnsw_index(const FaissIndexBinary* index) {
+    return reinterpret_cast<const faiss::IndexBinaryHNSW*>(index);
+}
+
+faiss::IndexBinaryHNSW* bhnsw_index(FaissIndexBinary* index) {
+    return reinterpret_cast<faiss::IndexBinaryHNSW*>(index);
+}
+
+} // namespace
+
+int gofaiss_IndexBinaryFlat_new(FaissIndexBinary** p_index, int d) {
+    GOFAISS_TRY
+    *p_index = reinterpret_cast<FaissIndexBinary*>(
+            new faiss::IndexBinaryFlat(d));
+    GOFAISS_CATCH
+}
+
+int gofaiss_IndexBinaryIVF_new(
+        FaissIndexBinary** p_index,
+        FaissIndexBinary* quantizer,
+        int d,
+        size_t nlist) {
+    GOFAISS_TRY
+    auto index =
+            new faiss::IndexBinaryIVF(binary_index(quantizer), d, nlist);
+    index->own_fields = true;
+    *p_index = reinterpret_cast<FaissIndexBinary*>(index);
+    GOFAISS_CATCH
+}
+
+int gofaiss_IndexBinaryHNSW_new(FaissIndexBinary** p_index, int d, int M) {
+    GOFAISS_TRY
+    *p_index = reinterpret_cast<FaissIndexBinary*>(
+            new faiss::IndexBinaryHNSW(d, M));
+    GOFAISS_CATCH
+}
+
+int gofaiss_IndexBinaryHash_new(FaissIndexBinary** p_index, int d, int b) {
+    GOFAISS_TRY
+    *p_index = reinterpret_cast<FaissIndexBinary*>(
+            new faiss::IndexBinaryHash(d, b));
+    GOFAISS_CATCH
+}
+
+int gofaiss_IndexBinaryMultiHash_new(
+        FaissIndexBinary** p_index,
+        int d,
+        int nhash,
+        int b) {
+    GOFAISS_TRY
+    *p_index = reinterpret_cast<FaissIndexBinary*>(
+            new faiss::IndexBinaryMultiHash(d, nhash, b));
+    GOFAISS_CATCH
+}
+
+int gofaiss_IndexBinaryIDMap_new(
+        FaissIndexBinary** p_index,
+        FaissIndexBinary* sub) {
+    GOFAISS_TRY
+    auto index = new faiss::IndexBinaryIDMap(binary_index(sub));
+    index->own_fields = true;
+    *p_index = reinterpret_cast<FaissIndexBinary*>(index);
+    GOFAISS_CATCH
+}
+
+FaissIndexBinary* gofaiss_IndexBinaryHNSW_cast(FaissIndexBinary* index) {
+    return reinterpret_cast<FaissIndexBinary*>(
+            dynamic_cast<faiss::IndexBinaryHNSW*>(binary_index(index)));
+}
+
+int gofaiss_IndexBinaryHNSW_efSearch(const FaissIndexBinary* index) {
+    return bhnsw_index(index)->hnsw.efSearch;
+}
+
+void gofaiss_IndexBinaryHNSW_set_efSearch(FaissIndexBinary* index, int ef) {
+    bhnsw_index(index)->hnsw.efSearch = ef;
+}
+
+int gofaiss_IndexBinaryHNSW_efConstruction(const FaissIndexBinary* index) {
+    return bhnsw_index(index)->hnsw.efConstruction;
+}
+
+void gofaiss_IndexBinaryHNSW_set_efConstruction(
+        FaissIndexBinary* index,
+        int ef) {
+    bhnsw_index(index)->hnsw.efConstruction = ef;
+}
+
+// IndexBinaryHash and IndexBinaryMultiHash are unrelated classes, the former
+// being reported as a single hash.
+
+int gofaiss_IndexBinaryHash_params(
+        const FaissIndexBinary* index,
+        int* b,
+        int* nhash,
+        int* nflip) {
+    const faiss::IndexBinary* bindex = binary_index(index);
+    if (auto h = dynamic_cast<const faiss::IndexBinaryHash*>(bindex)) {
+        *b = h->b;
+        *nhash = 1;
+        *nflip = h->nflip;
+        return 1;
+    }
+    if (auto mh = dynamic_cast<const faiss::IndexBinaryMultiHash*>(bindex)) {
+        *b = mh->b;
+        *nhash = mh->nhash;
+        *nflip = mh->nflip;
+        return 1;
+    }
+    return 0;
+}
+
+int gofaiss_IndexBinaryHash_set_nflip(FaissIndexBinary* index, int nflip) {
+    faiss::IndexBinary* bindex = binary_index(index);
+    if (auto h = dynamic_cast<faiss::IndexBinaryHash*>(bindex)) {
+        h->nflip = nflip;
+        return 1;
+    }
+    if (auto mh = dynamic_cast<faiss::IndexBinaryMultiHash*>(bindex)) {
+        mh->nflip = nflip;
+        return 1;
+    }
+    return 0;
+}
@@ -0,0 +1,55 @@
+package faiss
+
+import "sync"
+
+// SearchBuffers holds the output buffers of a SearchInto call. Distances is
+// []float32 for float indexes and []int32 for binary indexes.
+type SearchBuffers[D float32 | int32] struct {
+	Distances []D
+	Labels    []int64
+}
+
+// SearchBufferPool is a sync.Pool backed pool of search output buffers,
+// which lets services issuing many searches of the same shape reuse the
+// distances and labels slices instead of allocating them per query.
+//
+// A SearchBufferPool is safe for concurrent use. The zero value is ready to
+// use.
+type SearchBufferPool[D float32 | int32] struct {
+	pool sync.Pool
+}
+
+// NewSearchBufferPool returns an empty pool, e.g.
+// NewSearchBufferPool[float32]() for use with Index.SearchInto.
+func NewSearchBufferPool[D float32 | int32]() *SearchBufferPool[D] {
+	return &SearchBufferPool[D]{}
+}
+
+// Get returns buffers sized for the results of nq queries of k results each,
+// reusing pooled memory when it is large enough. The contents of the returned
+// buffers are undefined until they have been written by a search.
+func (p *SearchBufferPool[D]) Get(nq int, k int64) *SearchBuffers[D] {
+	size := int(int64(nq) * k)
+	b, _ := p.pool.Get().(*SearchBuffers[D])
+	if b == nil {
+		b = &SearchBuffers[D]{}
+	}
+	if cap(b.Distances) < size {
+		b.Distances = make([]D, size)
+	}
+	if cap(b.Labels) < size {
+		b.Labels = make([]int64, size)
+	}
+	b.Distances = b.Distances[:size]
+	b.Labels = b.Labels[:size]
+	return b
+}
+
+// Put returns b to the pool. b, and any slice obtained from it, must not be
+// used after Put.
+func (p *SearchBufferPool[D]) Put(b *SearchBuffers[D]) {
+	if b == nil {
+		return
+	}
+	p.pool.Put(b)
+}
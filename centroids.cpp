@@ -0,0 +1,130 @@
+// Replacement of the centroids of IVF indexes, see ivf_centroids.go.
+
+#include "gofaiss.h"
+#include "gofaiss_impl.h"
+
+#include <cstring>
+#include <vector>
+
+#include <faiss/IndexIVF.h>
+#include <faiss/IndexIVFPQ.h>
+#include <faiss/impl/FaissAssert.h>
+#include <faiss/invlists/DirectMap.h>
+#include <faiss/invlists/InvertedLists.h>
+
+using faiss::InvertedLists;
+
+namespace {
+
+faiss::IndexIVF* ivf_index(FaissIndex* index) {
+    faiss::IndexIVF* ivf = dynamic_cast<faiss::IndexIVF*>(
+            reinterpret_cast<faiss::Index*>(index));
+    FAISS_THROW_IF_NOT_MSG(ivf, "not an IVF index");
+    return ivf;
+}
+
+} // namespace
+
+int gofaiss_IndexIVF_reconstruct_list(
+        FaissIndex* index,
+        size_t list_no,
+        size_t n,
+        float* x) {
+    GOFAISS_TRY
+    const faiss::IndexIVF* ivf = ivf_index(index);
+    FAISS_THROW_IF_NOT_MSG(list_no < ivf->nlist, "invalid list number");
+    size_t size = ivf->invlists->list_size(list_no);
+    FAISS_THROW_IF_NOT_FMT(
+            size == n,
+            "list %zd holds %zd entries, expected %zd",
+            list_no,
+            size,
+            n);
+    for (size_t i = 0; i < n; i++) {
+        ivf->reconstruct_from_offset(list_no, i, x + i * ivf->d);
+    }
+    GOFAISS_CATCH
+}
+
+// All the vectors are reconstructed, before the quantizer is changed since
+// residual codes are relative to the old centroids. Codes which are not
+// residuals are then moved to their new list as is, which avoids quantizing
+// the vectors a second time, while residual codes are encoded again by adding
+// the reconstructed vectors back.
+int gofaiss_IndexIVF_replace_centroids(
+        FaissIndex* index,
+        idx_t k,
+        const float* centroids) {
+    GOFAISS_TRY
+    faiss::IndexIVF* ivf = ivf_index(index);
+    FAISS_THROW_IF_NOT_MSG(k > 0, "no centroids");
+    const InvertedLists* old_il = ivf->invlists;
+    size_t d = ivf->d;
+    size_t code_size = old_il->code_size;
+    bool move_codes = !ivf->by_residual &&
+            code_size != InvertedLists::INVALID_CODE_SIZE;
+
+    size_t ntotal = ivf->ntotal;
+    std::vector<idx_t> ids;
+    std::vector<float> x(ntotal * d);
+    std::vector<uint8_t> codes;
+    ids.reserve(ntotal);
+    if (move_codes) {
+        codes.reserve(ntotal * code_size);
+    }
+    for (size_t list_no = 0; list_no < ivf->nlist; list_no++) {
+        size_t size = old_il->list_size(list_no);
+        if (size == 0) {
+            continue;
+        }
+        InvertedLists::ScopedIds list_ids(old_il, list_no);
+        for (size_t i = 0; i < size; i++) {
+            ivf->reconstruct_from_offset(
+                    list_no, i, x.data() + ids.size() * d);
+            ids.push_back(list_ids.get()[i]);
+        }
+        if (move_codes) {
+            InvertedLists::ScopedCodes list_codes(old_il, list_no);
+            codes.insert(
+                    codes.end(),
+                    list_codes.get(),
+                    list_codes.get() + size * code_size);
+        }
+    }
+    FAISS_THROW_IF_NOT_MSG(ids.size() == ntotal, "inconsistent inverted lists");
+
+    // the direct map is rebuilt once the vectors are in their new lists.
+    faiss::DirectMap::Type dm_type = ivf->direct_map.type;
+    ivf->set_direct_map_type(faiss::DirectMap::NoMap);
+
+    ivf->quantizer->reset();
+    ivf->quantizer->add(k, centroids);
+    ivf->nlist = k;
+    auto new_il = new faiss::ArrayInvertedLists(k, code_size);
+    ivf->replace_invlists(new_il, true);
+
+    if (move_codes) {
+        std::vector<idx_t> assign(ntotal);
+        if (ntotal > 0) {
+            ivf->quantizer->assign(ntotal, x.data(), assign.data());
+        }
+        for (size_t i = 0; i < ntotal; i++) {
+            new_il->add_entry(
+                    assign[i], ids[i], codes.data() + i * code_size);
+        }
+    } else {
+        ivf->ntotal = 0;
+        if (ntotal > 0) {
+            ivf->add_with_ids(ntotal, x.data(), ids.data());
+        }
+    }
+
+    // precomputed tables depend on the centroids.
+    if (auto ivfpq = dynamic_cast<faiss::IndexIVFPQ*>(ivf)) {
+        if (ivfpq->use_precomputed_table != 0) {
+            ivfpq->precompute_table();
+        }
+    }
+    ivf->set_direct_map_type(dm_type);
+    GOFAISS_CATCH
+}
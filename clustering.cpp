@@ -0,0 +1,25 @@
+// Clustering statistics, see kmeans.go.
+
+#include "gofaiss.h"
+
+#include <faiss/Clustering.h>
+
+size_t gofaiss_Clustering_iteration_stats_size(
+        const FaissClustering* clustering) {
+    return reinterpret_cast<const faiss::Clustering*>(clustering)
+            ->iteration_stats.size();
+}
+
+void gofaiss_Clustering_iteration_stats(
+        const FaissClustering* clustering,
+        GofaissClusteringIterationStats* stats) {
+    const auto& v = reinterpret_cast<const faiss::Clustering*>(clustering)
+                            ->iteration_stats;
+    for (size_t i = 0; i < v.size(); i++) {
+        stats[i].obj = v[i].obj;
+        stats[i].time = v[i].time;
+        stats[i].time_search = v[i].time_search;
+        stats[i].imbalance_factor = v[i].imbalance_factor;
+        stats[i].nsplit = v[i].nsplit;
+    }
+}
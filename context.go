@@ -0,0 +1,367 @@
+package faiss
+
+/*
+#include <faiss/c_api/impl/AuxIndexStructures_c.h>
+#include "gofaiss.h"
+*/
+import "C"
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"runtime"
+)
+
+// The *Context variants of the search and add APIs split their input into
+// batches and check the context between two batches. Searches are also
+// aborted mid-batch: faiss polls a process-wide interrupt callback while it
+// searches, which this package installs to abort the calls whose context is
+// done, and only those, see interruptible.
+//
+// The callback is only installed while such calls are running, and then
+// chains to the callback the application installed, if any. While it is
+// installed, every faiss call of the process polling for interrupts, whether
+// made through a *Context variant or not, takes the global lock of faiss on
+// each of the periodic polls of each of its threads, which adds contention
+// to concurrent searches.
+//
+// NOTE: adds are not interrupted within a batch, as faiss leaves the index
+// half updated when it aborts an add, e.g. with vectors stored but missing
+// from the HNSW graph. The add batch size bounds the cancellation latency.
+const (
+	// number of query vectors searched between two context checks.
+	contextSearchBatchSize = 256
+	// number of vectors added between two context checks.
+	contextAddBatchSize = 4096
+)
+
+// interruptible runs call, which must make its faiss calls synchronously, so
+// that faiss aborts them once ctx is done. It reports whether they were
+// aborted, in which case err is the error of the aborted call.
+//
+// The faiss calls are tied to ctx through the OS thread running them, which
+// call must therefore not leave.
+func interruptible(ctx context.Context, call func() error) (
+	interrupted bool, err error) {
+	if ctx.Done() == nil {
+		return false, call()
+	}
+	token := C.gofaiss_interrupt_token_new()
+	defer C.gofaiss_interrupt_token_free(token)
+	cancelled := make(chan struct{})
+	stop := context.AfterFunc(ctx, func() {
+		C.gofaiss_interrupt_token_cancel(token)
+		close(cancelled)
+	})
+	defer func() {
+		// the token must outlive a cancellation which is under way.
+		if !stop() {
+			<-cancelled
+		}
+	}()
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	C.gofaiss_interrupt_begin(token)
+	defer func() {
+		interrupted = C.gofaiss_interrupt_end(token) != 0 && err != nil
+	}()
+	return false, call()
+}
+
+// contextError wraps the context error (context.Canceled or
+// context.DeadlineExceeded), recording how far the operation got.
+func contextError(ctx context.Context, op string, done, total int) error {
+	return fmt.Errorf("faiss %s interrupted after %d of %d vectors: %w",
+		op, done, total, ctx.Err())
+}
+
+// searchInBatches runs search over the n vectors of x, each vecLen values
+// long, in batches of contextSearchBatchSize queries, writing the results of
+// each batch straight into its slice of the output. Each batch is
+// interruptible.
+func searchInBatches[V float32 | uint8, D float32 | int32](ctx context.Context,
+	x []V, n, vecLen int, k int64,
+	search func(x []V, k int64, distances []D, labels []int64) error) ([]D, []int64, error) {
+	distances := make([]D, int64(n)*k)
+	labels := make([]int64, int64(n)*k)
+	for lo := 0; lo < n; lo += contextSearchBatchSize {
+		if ctx.Err() != nil {
+			return nil, nil, contextError(ctx, "search", lo, n)
+		}
+		hi := min(lo+contextSearchBatchSize, n)
+		interrupted, err := interruptible(ctx, func() error {
+			return search(x[lo*vecLen:hi*vecLen], k,
+				distances[int64(lo)*k:int64(hi)*k],
+				labels[int64(lo)*k:int64(hi)*k])
+		})
+		if interrupted {
+			return nil, nil, contextError(ctx, "search", lo, n)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return distances, labels, nil
+}
+
+// addInBatches runs add over the n vectors of x, each vecLen values long, in
+// batches of contextAddBatchSize vectors.
+func addInBatches[V float32 | uint8](ctx context.Context, x []V, n, vecLen int,
+	add func(x []V) error) error {
+	for lo := 0; lo < n; lo += contextAddBatchSize {
+		if ctx.Err() != nil {
+			return contextError(ctx, "add", lo, n)
+		}
+		hi := min(lo+contextAddBatchSize, n)
+		if err := add(x[lo*vecLen : hi*vecLen]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeRangeSearchResults concatenates the per batch results of a range
+// search into a single result covering all nq queries. The parts are deleted.
+func mergeRangeSearchResults(parts []*RangeSearchResult, nq int) (
+	*RangeSearchResult, error) {
+	defer func() {
+		for _, part := range parts {
+			part.Delete()
+		}
+	}()
+	if len(parts) == 1 {
+		rv := parts[0]
+		parts = nil
+		return rv, nil
+	}
+
+	var rsr *C.FaissRangeSearchResult
+	if c := C.faiss_RangeSearchResult_new_with(&rsr, C.idx_t(nq), 1); c != 0 {
+		return nil, newFaissError(ErrSearchFailed, getLastError(), int(c))
+	}
+	rv := newRangeSearchResult(rsr, parts[0].metric)
+
+	// lims holds the number of results per query until do_allocation turns
+	// it into offsets and allocates the labels and distances.
+	lims := rv.Lims()
+	q := 0
+	for _, part := range parts {
+		partLims := part.Lims()
+		for i := 0; i+1 < len(partLims); i++ {
+			lims[q] = partLims[i+1] - partLims[i]
+			q++
+		}
+	}
+	if c := C.faiss_RangeSearchResult_do_allocation(rsr); c != 0 {
+		rv.Delete()
+		return nil, newFaissError(ErrSearchFailed, getLastError(), int(c))
+	}
+
+	labels, distances := rv.Labels()
+	offset := 0
+	for _, part := range parts {
+		partLabels, partDistances := part.Labels()
+		copy(labels[offset:], partLabels)
+		copy(distances[offset:], partDistances)
+		offset += len(partLabels)
+	}
+	runtime.KeepAlive(rv)
+	return rv, nil
+}
+
+// SearchContext is like Search, but processes the queries in batches and
+// returns an error wrapping ctx.Err() if ctx is done before all of them have
+// been searched.
+func (idx *faissIndex) SearchContext(ctx context.Context, x []float32, k int64) (
+	[]float32, []int64, error) {
+	if idx.closed() {
+		return nil, nil, ErrIndexClosed
+	}
+	n, err := validateVectors(x, idx.D())
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := validateK(k); err != nil {
+		return nil, nil, err
+	}
+	return searchInBatches(ctx, x, n, idx.D(), k, idx.SearchInto)
+}
+
+// SearchWithOptionsContext is like SearchWithOptions, but processes the
+// queries in batches and returns an error wrapping ctx.Err() if ctx is done
+// before all of them have been searched.
+func (idx *faissIndex) SearchWithOptionsContext(ctx context.Context, x []float32,
+	k int64, sel Selector, params json.RawMessage) ([]float32, []int64, error) {
+	if idx.closed() {
+		return nil, nil, ErrIndexClosed
+	}
+	n, err := validateVectors(x, idx.D())
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := validateK(k); err != nil {
+		return nil, nil, err
+	}
+	return searchInBatches(ctx, x, n, idx.D(), k,
+		func(x []float32, k int64, distances []float32, labels []int64) error {
+			return idx.SearchWithOptionsInto(x, k, sel, params, distances, labels)
+		})
+}
+
+// AddContext is like Add, but adds the vectors in batches and returns an
+// error wrapping ctx.Err() if ctx is done before all of them have been added.
+// Vectors of the batches completed before that remain in the index.
+func (idx *faissIndex) AddContext(ctx context.Context, x []float32) error {
+	if idx.closed() {
+		return ErrIndexClosed
+	}
+	n, err := validateVectors(x, idx.D())
+	if err != nil {
+		return err
+	}
+	return addInBatches(ctx, x, n, idx.D(), idx.Add)
+}
+
+// RangeSearchContext is like RangeSearch, but processes the queries in batches
+// and returns an error wrapping ctx.Err() if ctx is done before all of them
+// have been searched.
+func (idx *faissIndex) RangeSearchContext(ctx context.Context, x []float32,
+	radius float32) (*RangeSearchResult, error) {
+	if idx.closed() {
+		return nil, ErrIndexClosed
+	}
+	d := idx.D()
+	n, err := validateVectors(x, d)
+	if err != nil {
+		return nil, err
+	}
+	parts := make([]*RangeSearchResult, 0, (n+contextSearchBatchSize-1)/contextSearchBatchSize)
+	for lo := 0; lo < n; lo += contextSearchBatchSize {
+		if ctx.Err() != nil {
+			err = contextError(ctx, "range search", lo, n)
+			break
+		}
+		hi := min(lo+contextSearchBatchSize, n)
+		var part *RangeSearchResult
+		var interrupted bool
+		interrupted, err = interruptible(ctx, func() (err error) {
+			part, err = idx.RangeSearch(x[lo*d:hi*d], radius)
+			return err
+		})
+		if interrupted {
+			err = contextError(ctx, "range search", lo, n)
+		}
+		if err != nil {
+			break
+		}
+		parts = append(parts, part)
+	}
+	if err != nil {
+		for _, part := range parts {
+			part.Delete()
+		}
+		return nil, err
+	}
+	return mergeRangeSearchResults(parts, n)
+}
+
+// SearchContext is like Search, but processes the queries in batches and
+// returns an error wrapping ctx.Err() if ctx is done before all of them have
+// been searched.
+func (b *faissBinaryIndex) SearchContext(ctx context.Context, xb []uint8, k int64) (
+	[]int32, []int64, error) {
+	if b.closed() {
+		return nil, nil, ErrIndexClosed
+	}
+	n, err := validateBinaryVectors(xb, b.D())
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := validateK(k); err != nil {
+		return nil, nil, err
+	}
+	return searchInBatches(ctx, xb, n, b.D()/8, k, b.SearchInto)
+}
+
+// SearchWithOptionsContext is like SearchWithOptions, but processes the
+// queries in batches and returns an error wrapping ctx.Err() if ctx is done
+// before all of them have been searched.
+func (b *faissBinaryIndex) SearchWithOptionsContext(ctx context.Context, xb []uint8,
+	k int64, sel Selector, params json.RawMessage) ([]int32, []int64, error) {
+	if b.closed() {
+		return nil, nil, ErrIndexClosed
+	}
+	n, err := validateBinaryVectors(xb, b.D())
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := validateK(k); err != nil {
+		return nil, nil, err
+	}
+	return searchInBatches(ctx, xb, n, b.D()/8, k,
+		func(xb []uint8, k int64, distances []int32, labels []int64) error {
+			return b.SearchWithOptionsInto(xb, k, sel, params, distances, labels)
+		})
+}
+
+// AddContext is like Add, but adds the vectors in batches and returns an
+// error wrapping ctx.Err() if ctx is done before all of them have been added.
+// Vectors of the batches completed before that remain in the index.
+func (b *faissBinaryIndex) AddContext(ctx context.Context, xb []uint8) error {
+	if b.closed() {
+		return ErrIndexClosed
+	}
+	n, err := validateBinaryVectors(xb, b.D())
+	if err != nil {
+		return err
+	}
+	return addInBatches(ctx, xb, n, b.D()/8, b.Add)
+}
+
+// RangeSearchContext is like RangeSearch, but processes the queries in batches
+// and returns an error wrapping ctx.Err() if ctx is done before all of them
+// have been searched.
+func (b *faissBinaryIndex) RangeSearchContext(ctx context.Context, xb []uint8,
+	radius int32) (*BinaryRangeSearchResult, error) {
+	if b.closed() {
+		return nil, ErrIndexClosed
+	}
+	codeSize := b.D() / 8
+	n, err := validateBinaryVectors(xb, b.D())
+	if err != nil {
+		return nil, err
+	}
+	parts := make([]*RangeSearchResult, 0, (n+contextSearchBatchSize-1)/contextSearchBatchSize)
+	for lo := 0; lo < n; lo += contextSearchBatchSize {
+		if ctx.Err() != nil {
+			err = contextError(ctx, "range search", lo, n)
+			break
+		}
+		hi := min(lo+contextSearchBatchSize, n)
+		var part *BinaryRangeSearchResult
+		var interrupted bool
+		interrupted, err = interruptible(ctx, func() (err error) {
+			part, err = b.RangeSearch(xb[lo*codeSize:hi*codeSize], radius)
+			return err
+		})
+		if interrupted {
+			err = contextError(ctx, "range search", lo, n)
+		}
+		if err != nil {
+			break
+		}
+		parts = append(parts, part.rsr)
+	}
+	if err != nil {
+		for _, part := range parts {
+			part.Delete()
+		}
+		return nil, err
+	}
+	rsr, err := mergeRangeSearchResults(parts, n)
+	if err != nil {
+		return nil, err
+	}
+	return &BinaryRangeSearchResult{rsr}, nil
+}
@@ -0,0 +1,156 @@
+// Structural description of indexes, see describe.go.
+
+#include "gofaiss.h"
+
+#include <faiss/IndexBinaryFlat.h>
+#include <faiss/IndexBinaryHNSW.h>
+#include <faiss/IndexBinaryHash.h>
+#include <faiss/IndexBinaryIVF.h>
+#include <faiss/IndexFlat.h>
+#include <faiss/IndexHNSW.h>
+#include <faiss/IndexIDMap.h>
+#include <faiss/IndexIVF.h>
+#include <faiss/IndexIVFFlat.h>
+#include <faiss/IndexIVFPQ.h>
+#include <faiss/IndexIVFRaBitQ.h>
+#include <faiss/IndexPQ.h>
+#include <faiss/IndexPreTransform.h>
+#include <faiss/IndexRaBitQ.h>
+#include <faiss/IndexScalarQuantizer.h>
+#include <faiss/VectorTransform.h>
+
+namespace {
+
+template <typename T>
+const T* as(const faiss::Index* index) {
+    return dynamic_cast<const T*>(index);
+}
+
+template <typename T>
+const T* as(const faiss::IndexBinary* index) {
+    return dynamic_cast<const T*>(index);
+}
+
+// describe_encoder fills the encoding of an index which is not a wrapper,
+// returning false if it is not recognized.
+bool describe_encoder(const faiss::Index* index, GofaissIndexNode* node) {
+    if (auto sq = as<faiss::IndexScalarQuantizer>(index)) {
+        node->encoder = GOFAISS_NODE_SQ;
+        node->sq_type = sq->sq.qtype;
+    } else if (auto pq = as<faiss::IndexPQ>(index)) {
+        node->encoder = GOFAISS_NODE_PQ;
+        node->pq_m = pq->pq.M;
+        node->pq_nbits = pq->pq.nbits;
+    } else if (as<faiss::IndexRaBitQ>(index)) {
+        node->encoder = GOFAISS_NODE_RABITQ;
+    } else if (as<faiss::IndexFlat>(index)) {
+        node->encoder = GOFAISS_NODE_FLAT;
+    } else if (auto ivfsq = as<faiss::IndexIVFScalarQuantizer>(index)) {
+        node->encoder = GOFAISS_NODE_SQ;
+        node->sq_type = ivfsq->sq.qtype;
+    } else if (auto ivfpq = as<faiss::IndexIVFPQ>(index)) {
+        node->encoder = GOFAISS_NODE_PQ;
+        node->pq_m = ivfpq->pq.M;
+        node->pq_nbits = ivfpq->pq.nbits;
+    } else if (as<faiss::IndexIVFRaBitQ>(index)) {
+        node->encoder = GOFAISS_NODE_RABITQ;
+    } else if (as<faiss::IndexIVFFlat>(index)) {
+        node->encoder = GOFAISS_NODE_FLAT;
+    } else {
+        return false;
+    }
+    return true;
+}
+
+} // namespace
+
+void gofaiss_Index_describe(const FaissIndex* cindex, GofaissIndexNode* node) {
+    auto index = reinterpret_cast<const faiss::Index*>(cindex);
+    *node = GofaissIndexNode{};
+
+    // IndexIDMap2 derives from IndexIDMap.
+    if (auto idmap2 = as<faiss::IndexIDMap2>(index)) {
+        node->kind = GOFAISS_NODE_IDMAP2;
+        node->sub = idmap2->index;
+    } else if (auto idmap = as<faiss::IndexIDMap>(index)) {
+        node->kind = GOFAISS_NODE_IDMAP;
+        node->sub = idmap->index;
+    } else if (auto pt = as<faiss::IndexPreTransform>(index)) {
+        node->kind = GOFAISS_NODE_PRETRANSFORM;
+        node->sub = pt->index;
+        node->n_transforms = pt->chain.size();
+    } else if (auto hnsw = as<faiss::IndexHNSW>(index)) {
+        node->kind = GOFAISS_NODE_HNSW;
+        node->m = hnsw->hnsw.nb_neighbors(1);
+        node->sub = hnsw->storage;
+    } else if (auto ivf = as<faiss::IndexIVF>(index)) {
+        node->kind = GOFAISS_NODE_IVF;
+        node->nlist = ivf->nlist;
+        node->quantizer = ivf->quantizer;
+        describe_encoder(index, node);
+    } else if (describe_encoder(index, node)) {
+        node->kind = node->encoder;
+    }
+}
+
+void gofaiss_IndexBinary_describe(
+        const FaissIndexBinary* cindex,
+        GofaissIndexNode* node) {
+    auto index = reinterpret_cast<const faiss::IndexBinary*>(cindex);
+    *node = GofaissIndexNode{};
+
+    if (auto idmap2 = as<faiss::IndexBinaryIDMap2>(index)) {
+        node->kind = GOFAISS_NODE_IDMAP2;
+        node->sub = idmap2->index;
+    } else if (auto idmap = as<faiss::IndexBinaryIDMap>(index)) {
+        node->kind = GOFAISS_NODE_IDMAP;
+        node->sub = idmap->index;
+    } else if (auto hnsw = as<faiss::IndexBinaryHNSW>(index)) {
+        node->kind = GOFAISS_NODE_BHNSW;
+        node->m = hnsw->hnsw.nb_neighbors(1);
+        node->sub = hnsw->storage;
+    } else if (auto ivf = as<faiss::IndexBinaryIVF>(index)) {
+        node->kind = GOFAISS_NODE_BIVF;
+        node->nlist = ivf->nlist;
+        node->quantizer = ivf->quantizer;
+        node->encoder = GOFAISS_NODE_BFLAT;
+    } else if (auto mh = as<faiss::IndexBinaryMultiHash>(index)) {
+        node->kind = GOFAISS_NODE_BMULTIHASH;
+        node->m = mh->b;
+        node->nhash = mh->nhash;
+    } else if (auto h = as<faiss::IndexBinaryHash>(index)) {
+        node->kind = GOFAISS_NODE_BHASH;
+        node->m = h->b;
+    } else if (as<faiss::IndexBinaryFlat>(index)) {
+        node->kind = GOFAISS_NODE_BFLAT;
+        node->encoder = GOFAISS_NODE_BFLAT;
+    }
+}
+
+void gofaiss_IndexPreTransform_transform(
+        const FaissIndex* cindex,
+        int i,
+        int* kind,
+        int* d_in,
+        int* d_out,
+        int* m) {
+    auto pt = reinterpret_cast<const faiss::IndexPreTransform*>(cindex);
+    const faiss::VectorTransform* vt = pt->chain[i];
+    *d_in = vt->d_in;
+    *d_out = vt->d_out;
+    *m = 0;
+    if (dynamic_cast<const faiss::PCAMatrix*>(vt)) {
+        *kind = GOFAISS_TRANSFORM_PCA;
+    } else if (auto opq = dynamic_cast<const faiss::OPQMatrix*>(vt)) {
+        *kind = GOFAISS_TRANSFORM_OPQ;
+        *m = opq->M;
+    } else if (dynamic_cast<const faiss::RandomRotationMatrix*>(vt)) {
+        *kind = GOFAISS_TRANSFORM_RR;
+    } else if (dynamic_cast<const faiss::ITQTransform*>(vt)) {
+        *kind = GOFAISS_TRANSFORM_ITQ;
+    } else if (dynamic_cast<const faiss::NormalizationTransform*>(vt)) {
+        *kind = GOFAISS_TRANSFORM_L2NORM;
+    } else {
+        *kind = GOFAISS_TRANSFORM_UNKNOWN;
+    }
+}
@@ -0,0 +1,287 @@
+package faiss
+
+/*
+#include "gofaiss.h"
+*/
+import "C"
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// IndexNode describes a component of an index, as returned by Describe and
+// DescribeBinary.
+type IndexNode struct {
+	// Kind is one of "Flat", "SQ", "PQ", "RaBitQ", "IVF", "HNSW", "IDMap",
+	// "IDMap2", "PreTransform", "BFlat", "BIVF", "BHNSW", "BHash",
+	// "BMultiHash", or "Unknown".
+	Kind   string
+	D      int
+	Metric int
+	Ntotal int64
+
+	// Nlist is the number of inverted lists of IVF indexes.
+	Nlist int
+	// M is the number of neighbors per node above the bottom level of HNSW
+	// indexes, the number of sub-quantizers of PQ encoders, and the number of
+	// bits per hash of hash indexes.
+	M int
+	// Nbits is the number of bits per sub-quantizer of PQ encoders.
+	Nbits int
+	// Nhash is the number of hashes of multi-hash indexes.
+	Nhash int
+	// SQType names the quantizer type of SQ encoders, such as "SQ8" or
+	// "SQfp16".
+	SQType string
+
+	// Sub is the index wrapped by IDMap and PreTransform indexes, and the
+	// storage of HNSW indexes.
+	Sub *IndexNode
+	// Quantizer is the coarse quantizer of IVF indexes.
+	Quantizer *IndexNode
+	// Encoder describes how the inverted lists of IVF indexes encode the
+	// vectors, its Kind being "Flat", "SQ", "PQ", "RaBitQ" or "BFlat".
+	Encoder *IndexNode
+	// Transforms lists the vector transforms applied by PreTransform indexes,
+	// in index_factory terms, such as "PCA64" or "OPQ16_64".
+	Transforms []string
+}
+
+// IndexDescription is the structure of an index, along with a best-effort
+// index_factory description from which an empty equivalent index can be
+// built with IndexFactory (or BinaryIndexFactory for binary indexes), using
+// the metric of the root node.
+type IndexDescription struct {
+	Root *IndexNode
+	// Factory is empty if the index has a component which cannot be
+	// expressed in index_factory terms.
+	Factory string
+}
+
+var nodeKinds = map[C.int]string{
+	C.GOFAISS_NODE_FLAT:         "Flat",
+	C.GOFAISS_NODE_SQ:           "SQ",
+	C.GOFAISS_NODE_PQ:           "PQ",
+	C.GOFAISS_NODE_RABITQ:       "RaBitQ",
+	C.GOFAISS_NODE_IVF:          "IVF",
+	C.GOFAISS_NODE_HNSW:         "HNSW",
+	C.GOFAISS_NODE_IDMAP:        "IDMap",
+	C.GOFAISS_NODE_IDMAP2:       "IDMap2",
+	C.GOFAISS_NODE_PRETRANSFORM: "PreTransform",
+	C.GOFAISS_NODE_BFLAT:        "BFlat",
+	C.GOFAISS_NODE_BIVF:         "BIVF",
+	C.GOFAISS_NODE_BHNSW:        "BHNSW",
+	C.GOFAISS_NODE_BHASH:        "BHash",
+	C.GOFAISS_NODE_BMULTIHASH:   "BMultiHash",
+}
+
+func nodeKind(kind C.int) string {
+	if rv, ok := nodeKinds[kind]; ok {
+		return rv
+	}
+	return "Unknown"
+}
+
+// sqTypes names the faiss ScalarQuantizer::QuantizerType values. The uniform
+// types have no index_factory name.
+var sqTypes = []string{
+	"SQ8", "SQ4", "SQ8_uniform", "SQ4_uniform", "SQfp16", "SQ8_direct",
+	"SQ6", "SQbf16", "SQ8_direct_signed",
+}
+
+func sqType(qtype C.int) string {
+	if qtype >= 0 && int(qtype) < len(sqTypes) {
+		return sqTypes[qtype]
+	}
+	return fmt.Sprintf("SQ(%d)", int(qtype))
+}
+
+// encoderNode returns the encoder described by the encoder fields of n.
+func encoderNode(n *C.GofaissIndexNode) *IndexNode {
+	rv := &IndexNode{Kind: nodeKind(n.encoder)}
+	switch n.encoder {
+	case C.GOFAISS_NODE_SQ:
+		rv.SQType = sqType(n.sq_type)
+	case C.GOFAISS_NODE_PQ:
+		rv.M, rv.Nbits = int(n.pq_m), int(n.pq_nbits)
+	}
+	return rv
+}
+
+func describeIndex(ptr *C.FaissIndex) *IndexNode {
+	var n C.GofaissIndexNode
+	C.gofaiss_Index_describe(ptr, &n)
+	rv := encoderNode(&n)
+	rv.Kind = nodeKind(n.kind)
+	rv.D = int(C.faiss_Index_d(ptr))
+	rv.Metric = int(C.faiss_Index_metric_type(ptr))
+	rv.Ntotal = int64(C.faiss_Index_ntotal(ptr))
+	switch n.kind {
+	case C.GOFAISS_NODE_IVF:
+		rv.Nlist = int(n.nlist)
+		rv.M, rv.Nbits, rv.SQType = 0, 0, ""
+		rv.Encoder = encoderNode(&n)
+		if n.quantizer != nil {
+			rv.Quantizer = describeIndex((*C.FaissIndex)(n.quantizer))
+		}
+	case C.GOFAISS_NODE_HNSW:
+		rv.M = int(n.m)
+	case C.GOFAISS_NODE_PRETRANSFORM:
+		for i := range int(n.n_transforms) {
+			var kind, dIn, dOut, m C.int
+			C.gofaiss_IndexPreTransform_transform(ptr, C.int(i), &kind, &dIn,
+				&dOut, &m)
+			rv.Transforms = append(rv.Transforms, transformFactory(kind, dOut, m))
+		}
+	}
+	if n.sub != nil {
+		rv.Sub = describeIndex((*C.FaissIndex)(n.sub))
+	}
+	return rv
+}
+
+func describeBinaryIndex(ptr *C.FaissIndexBinary) *IndexNode {
+	var n C.GofaissIndexNode
+	C.gofaiss_IndexBinary_describe(ptr, &n)
+	rv := &IndexNode{
+		Kind:   nodeKind(n.kind),
+		D:      int(C.faiss_IndexBinary_d(ptr)),
+		Metric: int(C.faiss_IndexBinary_metric_type(ptr)),
+		Ntotal: int64(C.faiss_IndexBinary_ntotal(ptr)),
+	}
+	switch n.kind {
+	case C.GOFAISS_NODE_BIVF:
+		rv.Nlist = int(n.nlist)
+		rv.Encoder = encoderNode(&n)
+		if n.quantizer != nil {
+			rv.Quantizer = describeBinaryIndex((*C.FaissIndexBinary)(n.quantizer))
+		}
+	case C.GOFAISS_NODE_BHNSW, C.GOFAISS_NODE_BHASH:
+		rv.M = int(n.m)
+	case C.GOFAISS_NODE_BMULTIHASH:
+		rv.M, rv.Nhash = int(n.m), int(n.nhash)
+	}
+	if n.sub != nil {
+		rv.Sub = describeBinaryIndex((*C.FaissIndexBinary)(n.sub))
+	}
+	return rv
+}
+
+// transformFactory returns the index_factory name of a vector transform.
+func transformFactory(kind, dOut, m C.int) string {
+	switch kind {
+	case C.GOFAISS_TRANSFORM_PCA:
+		return fmt.Sprintf("PCA%d", dOut)
+	case C.GOFAISS_TRANSFORM_OPQ:
+		return fmt.Sprintf("OPQ%d_%d", m, dOut)
+	case C.GOFAISS_TRANSFORM_RR:
+		return fmt.Sprintf("RR%d", dOut)
+	case C.GOFAISS_TRANSFORM_ITQ:
+		return fmt.Sprintf("ITQ%d", dOut)
+	case C.GOFAISS_TRANSFORM_L2NORM:
+		return "L2norm"
+	}
+	return ""
+}
+
+// encoderFactory returns the index_factory name of an encoder.
+func encoderFactory(n *IndexNode) string {
+	switch n.Kind {
+	case "Flat", "RaBitQ":
+		return n.Kind
+	case "SQ":
+		if strings.HasSuffix(n.SQType, "_uniform") || strings.HasPrefix(n.SQType, "SQ(") {
+			return ""
+		}
+		return n.SQType
+	case "PQ":
+		return fmt.Sprintf("PQ%dx%d", n.M, n.Nbits)
+	}
+	return ""
+}
+
+// factory returns the index_factory description of n, or "" if it has none.
+func factory(n *IndexNode) string {
+	join := func(parts ...string) string {
+		for _, part := range parts {
+			if part == "" {
+				return ""
+			}
+		}
+		return strings.Join(parts, ",")
+	}
+	switch n.Kind {
+	case "IDMap", "IDMap2":
+		if n.Sub == nil {
+			return ""
+		}
+		return join(n.Kind, factory(n.Sub))
+	case "PreTransform":
+		if n.Sub == nil {
+			return ""
+		}
+		return join(append(append([]string{}, n.Transforms...), factory(n.Sub))...)
+	case "HNSW":
+		if n.Sub == nil {
+			return ""
+		}
+		return join(fmt.Sprintf("HNSW%d", n.M), encoderFactory(n.Sub))
+	case "IVF":
+		if n.Quantizer == nil {
+			return ""
+		}
+		ivf := fmt.Sprintf("IVF%d", n.Nlist)
+		switch {
+		case n.Quantizer.Kind == "HNSW":
+			ivf += fmt.Sprintf("_HNSW%d", n.Quantizer.M)
+		case n.Quantizer.Kind != "Flat":
+			return ""
+		}
+		return join(ivf, encoderFactory(n.Encoder))
+	case "BFlat":
+		return "BFlat"
+	case "BIVF":
+		if n.Quantizer == nil {
+			return ""
+		}
+		if n.Quantizer.Kind == "BHNSW" {
+			return fmt.Sprintf("BIVF%d_HNSW%d", n.Nlist, n.Quantizer.M)
+		}
+		if n.Quantizer.Kind != "BFlat" {
+			return ""
+		}
+		return fmt.Sprintf("BIVF%d", n.Nlist)
+	case "BHNSW":
+		return fmt.Sprintf("BHNSW%d", n.M)
+	case "BHash":
+		return fmt.Sprintf("BHash%d", n.M)
+	case "BMultiHash":
+		return fmt.Sprintf("BHash%dx%d", n.Nhash, n.M)
+	}
+	return encoderFactory(n)
+}
+
+// Describe returns the structure of idx, such as IDMap2 wrapping an IVF index
+// with a flat coarse quantizer and SQ8 encoded inverted lists, along with
+// its index_factory description ("IDMap2,IVF1024,SQ8").
+func Describe(idx Index) (*IndexDescription, error) {
+	ptr := idx.cPtr()
+	if ptr == nil {
+		return nil, ErrIndexClosed
+	}
+	defer runtime.KeepAlive(idx)
+	root := describeIndex(ptr)
+	return &IndexDescription{Root: root, Factory: factory(root)}, nil
+}
+
+// DescribeBinary is the binary index counterpart of Describe.
+func DescribeBinary(idx BinaryIndex) (*IndexDescription, error) {
+	ptr := idx.bPtr()
+	if ptr == nil {
+		return nil, ErrIndexClosed
+	}
+	defer runtime.KeepAlive(idx)
+	root := describeBinaryIndex(ptr)
+	return &IndexDescription{Root: root, Factory: factory(root)}, nil
+}
@@ -0,0 +1,235 @@
+package faiss
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// The envelope format wraps a serialized index with a fixed size header
+// describing it and checksumming it, so that truncated or corrupted data is
+// detected before it reaches faiss. All fields are little endian:
+//
+//	offset  size  field
+//	     0     4  magic "GFIE"
+//	     4     2  format version
+//	     6     1  index kind, see IndexKind
+//	     7     1  reserved, zero
+//	     8     4  dimension, in bits for binary indexes
+//	    12     4  metric type
+//	    16     8  number of indexed vectors
+//	    24     8  payload length
+//	    32     4  CRC-32C of the payload
+//	    36     4  CRC-32C of the first 36 bytes of the header
+//	    40        payload: the index as serialized by faiss
+const (
+	envelopeMagic      = "GFIE"
+	envelopeVersion    = 1
+	envelopeHeaderSize = 40
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// IndexKind is the kind of index held by an envelope.
+type IndexKind uint8
+
+const (
+	IndexKindFloat  IndexKind = 0
+	IndexKindBinary IndexKind = 1
+)
+
+func (k IndexKind) String() string {
+	switch k {
+	case IndexKindFloat:
+		return "float"
+	case IndexKindBinary:
+		return "binary"
+	}
+	return fmt.Sprintf("IndexKind(%d)", uint8(k))
+}
+
+// EnvelopeHeader describes the index held by an envelope.
+type EnvelopeHeader struct {
+	Version    uint16
+	Kind       IndexKind
+	D          int
+	Metric     int
+	Ntotal     int64
+	PayloadLen uint64
+	Checksum   uint32
+}
+
+func (h *EnvelopeHeader) marshal() []byte {
+	buf := make([]byte, envelopeHeaderSize)
+	copy(buf, envelopeMagic)
+	binary.LittleEndian.PutUint16(buf[4:], h.Version)
+	buf[6] = byte(h.Kind)
+	binary.LittleEndian.PutUint32(buf[8:], uint32(h.D))
+	binary.LittleEndian.PutUint32(buf[12:], uint32(int32(h.Metric)))
+	binary.LittleEndian.PutUint64(buf[16:], uint64(h.Ntotal))
+	binary.LittleEndian.PutUint64(buf[24:], h.PayloadLen)
+	binary.LittleEndian.PutUint32(buf[32:], h.Checksum)
+	binary.LittleEndian.PutUint32(buf[36:], crc32.Checksum(buf[:36], castagnoli))
+	return buf
+}
+
+// corruptIndexError returns an ErrCorruptIndex error with details.
+func corruptIndexError(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrCorruptIndex, fmt.Sprintf(format, args...))
+}
+
+// ParseEnvelopeHeader parses and verifies the header of an envelope, without
+// verifying the payload.
+func ParseEnvelopeHeader(buf []byte) (*EnvelopeHeader, error) {
+	if len(buf) < envelopeHeaderSize {
+		return nil, corruptIndexError("%d bytes is too short for the %d byte "+
+			"envelope header", len(buf), envelopeHeaderSize)
+	}
+	if string(buf[:4]) != envelopeMagic {
+		return nil, corruptIndexError("bad envelope magic %q", buf[:4])
+	}
+	if sum, want := crc32.Checksum(buf[:36], castagnoli),
+		binary.LittleEndian.Uint32(buf[36:]); sum != want {
+		return nil, corruptIndexError("envelope header checksum %08x, "+
+			"expected %08x", sum, want)
+	}
+	h := &EnvelopeHeader{
+		Version:    binary.LittleEndian.Uint16(buf[4:]),
+		Kind:       IndexKind(buf[6]),
+		D:          int(binary.LittleEndian.Uint32(buf[8:])),
+		Metric:     int(int32(binary.LittleEndian.Uint32(buf[12:]))),
+		Ntotal:     int64(binary.LittleEndian.Uint64(buf[16:])),
+		PayloadLen: binary.LittleEndian.Uint64(buf[24:]),
+		Checksum:   binary.LittleEndian.Uint32(buf[32:]),
+	}
+	if h.Version != envelopeVersion {
+		return nil, corruptIndexError("unsupported envelope version %d",
+			h.Version)
+	}
+	return h, nil
+}
+
+// openEnvelope verifies buf is a well-formed envelope holding an index of the
+// given kind, and returns its header and payload.
+func openEnvelope(buf []byte, kind IndexKind) (*EnvelopeHeader, []byte, error) {
+	h, err := ParseEnvelopeHeader(buf)
+	if err != nil {
+		return nil, nil, err
+	}
+	if h.Kind != kind {
+		return nil, nil, corruptIndexError("envelope holds a %s index, "+
+			"expected a %s one", h.Kind, kind)
+	}
+	payload := buf[envelopeHeaderSize:]
+	if uint64(len(payload)) != h.PayloadLen {
+		return nil, nil, corruptIndexError("payload is %d bytes, expected %d",
+			len(payload), h.PayloadLen)
+	}
+	if h.PayloadLen == 0 {
+		return nil, nil, corruptIndexError("empty payload")
+	}
+	if sum := crc32.Checksum(payload, castagnoli); sum != h.Checksum {
+		return nil, nil, corruptIndexError("payload checksum %08x, expected %08x",
+			sum, h.Checksum)
+	}
+	return h, payload, nil
+}
+
+// checkEnvelopeIndex verifies the deserialized index matches its header.
+func checkEnvelopeIndex(h *EnvelopeHeader, d, metric int, ntotal int64) error {
+	if d != h.D || metric != h.Metric || ntotal != h.Ntotal {
+		return corruptIndexError("index has d=%d metric=%d ntotal=%d, "+
+			"envelope has d=%d metric=%d ntotal=%d",
+			d, metric, ntotal, h.D, h.Metric, h.Ntotal)
+	}
+	return nil
+}
+
+// writeEnvelope serializes an index through write, prefixed with h.
+func writeEnvelope(h *EnvelopeHeader, write func(w io.Writer) error) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Write(make([]byte, envelopeHeaderSize))
+	crc := crc32.New(castagnoli)
+	if err := write(io.MultiWriter(&buf, crc)); err != nil {
+		return nil, err
+	}
+	rv := buf.Bytes()
+	h.Version = envelopeVersion
+	h.PayloadLen = uint64(len(rv) - envelopeHeaderSize)
+	h.Checksum = crc.Sum32()
+	copy(rv, h.marshal())
+	return rv, nil
+}
+
+// WriteIndexEnveloped is like WriteIndexIntoBuffer, but wraps the serialized
+// index in a checksummed envelope, to be read with ReadIndexEnveloped.
+func WriteIndexEnveloped(idx Index) ([]byte, error) {
+	if idx.cPtr() == nil {
+		return nil, ErrIndexClosed
+	}
+	h := &EnvelopeHeader{
+		Kind:   IndexKindFloat,
+		D:      idx.D(),
+		Metric: idx.MetricType(),
+		Ntotal: idx.Ntotal(),
+	}
+	return writeEnvelope(h, func(w io.Writer) error {
+		return WriteIndexTo(idx, w)
+	})
+}
+
+// ReadIndexEnveloped reads an index written by WriteIndexEnveloped, returning
+// an error wrapping ErrCorruptIndex if the envelope or the index it holds
+// fails verification. The payload is verified before being handed to faiss.
+func ReadIndexEnveloped(buf []byte, ioflags int) (*IndexImpl, error) {
+	h, payload, err := openEnvelope(buf, IndexKindFloat)
+	if err != nil {
+		return nil, err
+	}
+	idx, err := ReadIndexFromBuffer(payload, ioflags)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkEnvelopeIndex(h, idx.D(), idx.MetricType(), idx.Ntotal()); err != nil {
+		idx.Close()
+		return nil, err
+	}
+	return idx, nil
+}
+
+// WriteBinaryIndexEnveloped is the binary index counterpart of
+// WriteIndexEnveloped. The dimension is recorded in bits.
+func WriteBinaryIndexEnveloped(idx BinaryIndex) ([]byte, error) {
+	if idx.bPtr() == nil {
+		return nil, ErrIndexClosed
+	}
+	h := &EnvelopeHeader{
+		Kind:   IndexKindBinary,
+		D:      idx.D(),
+		Metric: idx.MetricType(),
+		Ntotal: idx.Ntotal(),
+	}
+	return writeEnvelope(h, func(w io.Writer) error {
+		return WriteBinaryIndexTo(idx, w)
+	})
+}
+
+// ReadBinaryIndexEnveloped is the binary index counterpart of
+// ReadIndexEnveloped.
+func ReadBinaryIndexEnveloped(buf []byte, ioflags int) (*BinaryIndexImpl, error) {
+	h, payload, err := openEnvelope(buf, IndexKindBinary)
+	if err != nil {
+		return nil, err
+	}
+	idx, err := ReadBinaryIndexFromBuffer(payload, ioflags)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkEnvelopeIndex(h, idx.D(), idx.MetricType(), idx.Ntotal()); err != nil {
+		idx.Close()
+		return nil, err
+	}
+	return idx, nil
+}
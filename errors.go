@@ -21,10 +21,16 @@ func (e *faissError) Error() string {
 	return fmt.Sprintf("faiss %s: %s (code %d)", e.errType, e.err, e.errCode)
 }
 
-// returns the error type which can allow usage of
-// errors.Is and errors.As for error handling.
+// returns the underlying error, which can allow usage of errors.Is and
+// errors.As on it, e.g. on the error of the io.Writer passed to WriteIndexTo.
 func (e *faissError) Unwrap() error {
-	return e.errType
+	return e.err
+}
+
+// reports whether the error type matches target, which can allow usage of
+// errors.Is with the error types above.
+func (e *faissError) Is(target error) bool {
+	return errors.Is(e.errType, target)
 }
 
 // create a new faissError with the given error type, underlying error, and error code.
@@ -40,8 +46,11 @@ func newFaissError(errType, err error, errCode int) error {
 var (
 	// ---- Construction ----
 
-	ErrCreateIndexFailed    = errors.New("create index failed")
-	ErrCreateSelectorFailed = errors.New("create selector failed")
+	ErrCreateIndexFailed     = errors.New("create index failed")
+	ErrCreateSelectorFailed  = errors.New("create selector failed")
+	ErrCreateTransformFailed = errors.New("create vector transform failed")
+	ErrCreateQuantizerFailed = errors.New("create quantizer failed")
+	ErrCreateKmeansFailed    = errors.New("create k-means failed")
 
 	// ---- Configuration ----
 
@@ -50,14 +59,21 @@ var (
 
 	// ---- Vector ops ----
 
-	ErrAddFailed          = errors.New("add vectors failed")
-	ErrTrainFailed        = errors.New("train index failed")
-	ErrSearchFailed       = errors.New("search index failed")
-	ErrReconstructFailed  = errors.New("reconstruct vector failed")
-	ErrResetIndexFailed   = errors.New("reset index failed")
-	ErrSetQuantizerFailed = errors.New("set quantizer failed")
-	ErrMergeFromFailed    = errors.New("merge from index failed")
-	ErrRemoveIDsFailed    = errors.New("remove IDs failed")
+	ErrAddFailed              = errors.New("add vectors failed")
+	ErrTrainFailed            = errors.New("train index failed")
+	ErrSearchFailed           = errors.New("search index failed")
+	ErrReconstructFailed      = errors.New("reconstruct vector failed")
+	ErrResetIndexFailed       = errors.New("reset index failed")
+	ErrSetQuantizerFailed     = errors.New("set quantizer failed")
+	ErrMergeFromFailed        = errors.New("merge from index failed")
+	ErrRemoveIDsFailed        = errors.New("remove IDs failed")
+	ErrUpdateVectorsFailed    = errors.New("update vectors failed")
+	ErrReplaceCentroidsFailed = errors.New("replace centroids failed")
+	ErrRebalanceFailed        = errors.New("rebalance index failed")
+	ErrSplitFailed            = errors.New("split index failed")
+	ErrApplyTransformFailed   = errors.New("apply vector transform failed")
+	ErrEncodeFailed           = errors.New("encode vectors failed")
+	ErrDecodeFailed           = errors.New("decode vectors failed")
 
 	// ---- Read-only index introspection ----
 
@@ -67,6 +83,7 @@ var (
 
 	ErrWriteIndexFailed = errors.New("write index failed")
 	ErrReadIndexFailed  = errors.New("read index failed")
+	ErrCorruptIndex     = errors.New("serialized index is corrupt")
 
 	// ---- GPU ----
 
@@ -76,18 +93,41 @@ var (
 	ErrGPUContextFailed   = errors.New("GPU context init failed")
 	ErrGPUOutOfMemory     = errors.New("GPU out of memory")
 
+	// ---- Input validation ----
+
+	ErrEmptyInput        = errors.New("input is empty")
+	ErrDimensionMismatch = errors.New("input length does not match index dimension")
+	ErrIDCountMismatch   = errors.New("number of IDs does not match number of vectors")
+	ErrInvalidK          = errors.New("k must be positive")
+	ErrBufferTooSmall    = errors.New("buffer is too small")
+	ErrInvalidList       = errors.New("inverted list number is out of range")
+	ErrIDNotFound        = errors.New("ID not found")
+
 	// ---- State / pre-condition errors ----
 
-	ErrIndexNil      = errors.New("index is nil")
-	ErrSelectorNil   = errors.New("selector is nil")
-	ErrNotIDMapIndex = errors.New("index is not an IDMap index")
-	ErrNotIVFIndex   = errors.New("index is not an IVF index")
-	ErrNotBIVFIndex  = errors.New("index is not a binary IVF index")
+	ErrIndexNil         = errors.New("index is nil")
+	ErrIndexClosed      = errors.New("index is closed")
+	ErrIndexReadOnly    = errors.New("index is read-only")
+	ErrSelectorNil      = errors.New("selector is nil")
+	ErrSelectorClosed   = errors.New("selector is closed")
+	ErrSelectorPanicked = errors.New("ID selector predicate panicked")
+	ErrTransformClosed  = errors.New("vector transform is closed")
+	ErrQuantizerClosed  = errors.New("quantizer is closed")
+	ErrAlreadyOwned     = errors.New("already owned by another index")
+	ErrParamsClosed     = errors.New("params are closed")
+	ErrNotIDMapIndex    = errors.New("index is not an IDMap index")
+	ErrNotIVFIndex      = errors.New("index is not an IVF index")
+	ErrNotBIVFIndex     = errors.New("index is not a binary IVF index")
+	ErrNotHNSWIndex     = errors.New("index is not an HNSW index")
+	ErrNotHashIndex     = errors.New("index is not a binary hash index")
+	ErrNoDirectMap      = errors.New("index has no direct map")
+	ErrNotTrained       = errors.New("not trained")
 
 	// ---- Unsupported operations ----
 
 	ErrMergeFromNotSupported    = errors.New("merge from is not supported for this index type")
 	ErrSetQuantizerNotSupported = errors.New("set quantizer not supported for this index type")
+	ErrUpdateNotSupported       = errors.New("update vectors is not supported for this index type")
 )
 
 // getLastError returns the last error message set by the FAISS C API.
@@ -5,7 +5,8 @@
 package faiss
 
 /*
-#cgo LDFLAGS: -lfaiss_c
+#cgo LDFLAGS: -lfaiss_c -lfaiss
+#cgo CXXFLAGS: -std=c++17
 
 #include <faiss/c_api/Index_c.h>
 #include <faiss/c_api/utils/distances_c.h>
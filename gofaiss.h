@@ -0,0 +1,435 @@
+// C declarations of the functions implemented by the C++ sources of this
+// package, for the parts of faiss the C API does not cover.
+//
+// The functions follow the conventions of the faiss C API: they return 0 on
+// success and a negative error code on failure, in which case the error
+// message can be retrieved with faiss_get_last_error.
+
+#ifndef GOFAISS_H
+#define GOFAISS_H
+
+#include <stddef.h>
+#include <stdint.h>
+
+#include <faiss/c_api/Clustering_c.h>
+#include <faiss/c_api/IndexBinary_c.h>
+#include <faiss/c_api/Index_c.h>
+#include <faiss/c_api/VectorTransform_c.h>
+#include <faiss/c_api/impl/AuxIndexStructures_c.h>
+
+#ifdef __cplusplus
+extern "C" {
+#endif
+
+// ---- Go callback selectors (selector_func.cpp) ----
+
+// Callbacks implemented in Go, see selector_func.go.
+int goFaissIDSelectorIsMember(uintptr_t handle, int64_t id);
+// goFaissIDSelectorFillChunk returns 1 if the predicate panicked on an ID.
+int goFaissIDSelectorFillChunk(uintptr_t handle, int64_t start, size_t n,
+                               uint8_t* members);
+
+// Creates a selector calling goFaissIDSelectorIsMember with handle for each
+// ID faiss asks about.
+int gofaiss_IDSelectorFunc_new(FaissIDSelector** p_sel, uintptr_t handle);
+
+// Creates a selector calling goFaissIDSelectorFillChunk with handle to
+// evaluate chunk_size consecutive IDs at once, caching the results of up to
+// max_chunks chunks.
+int gofaiss_IDSelectorFuncBatched_new(FaissIDSelector** p_sel,
+                                      uintptr_t handle, size_t chunk_size,
+                                      size_t max_chunks);
+
+// Drops the results cached by a selector created by
+// gofaiss_IDSelectorFuncBatched_new, and does nothing for other selectors.
+void gofaiss_IDSelectorFuncBatched_reset(FaissIDSelector* sel);
+
+// Counts the IDs in [0, n) sel selects, calling is_member for each in turn,
+// which is how the selector benchmarks measure the cost of a selector alone.
+int gofaiss_IDSelector_count_members(const FaissIDSelector* sel, int64_t n,
+                                     int64_t* p_count);
+
+// ---- HNSW (hnsw.cpp) ----
+
+int gofaiss_IndexHNSWFlat_new(FaissIndex** p_index, int d, int M,
+                              FaissMetricType metric);
+
+// Returns index if it is an HNSW index, NULL otherwise.
+FaissIndex* gofaiss_IndexHNSW_cast(FaissIndex* index);
+
+int gofaiss_IndexHNSW_efSearch(const FaissIndex* index);
+void gofaiss_IndexHNSW_set_efSearch(FaissIndex* index, int ef);
+int gofaiss_IndexHNSW_efConstruction(const FaissIndex* index);
+void gofaiss_IndexHNSW_set_efConstruction(FaissIndex* index, int ef);
+
+// Returns the highest level of the graph, -1 if it is empty.
+int gofaiss_IndexHNSW_max_level(const FaissIndex* index);
+
+// Returns the node searches start from, -1 if the graph is empty.
+idx_t gofaiss_IndexHNSW_entry_point(const FaissIndex* index);
+
+// Returns the highest level of node id.
+int gofaiss_IndexHNSW_level(const FaissIndex* index, idx_t id);
+
+// Copies the highest level of each of the ntotal nodes into levels.
+void gofaiss_IndexHNSW_levels(const FaissIndex* index, int* levels);
+
+// Returns the maximum number of neighbors of a node on level.
+int gofaiss_IndexHNSW_nb_neighbors(const FaissIndex* index, int level);
+
+// Copies the neighbors of node id on level, which must not be above the
+// highest level of the node, into neighbors, which must hold at least
+// nb_neighbors(level) values, and sets *n to their number.
+void gofaiss_IndexHNSW_neighbors(const FaissIndex* index, idx_t id, int level,
+                                 idx_t* neighbors, size_t* n);
+
+int gofaiss_SearchParametersHNSW_new(FaissSearchParameters** p_sp,
+                                     FaissIDSelector* sel, int efSearch);
+
+// ---- Streaming serialization (index_stream.cpp) ----
+
+// Callbacks implemented in Go, see index_stream.go. They return the number of
+// bytes written or read, which is less than n on error.
+size_t goFaissStreamWrite(uintptr_t handle, void* ptr, size_t n);
+size_t goFaissStreamRead(uintptr_t handle, void* ptr, size_t n);
+
+// Serialize an index through goFaissStreamWrite with handle.
+int gofaiss_write_index_stream(const FaissIndex* index, uintptr_t handle,
+                               int io_flags);
+int gofaiss_write_index_binary_stream(const FaissIndexBinary* index,
+                                      uintptr_t handle);
+
+// Deserialize an index through goFaissStreamRead with handle.
+int gofaiss_read_index_stream(uintptr_t handle, int io_flags,
+                              FaissIndex** p_out);
+int gofaiss_read_index_binary_stream(uintptr_t handle, int io_flags,
+                                     FaissIndexBinary** p_out);
+
+// Serialize and deserialize a vector transform the same way.
+int gofaiss_write_VectorTransform_stream(const FaissVectorTransform* vt,
+                                         uintptr_t handle);
+int gofaiss_read_VectorTransform_stream(uintptr_t handle,
+                                        FaissVectorTransform** p_out);
+
+typedef struct GofaissProductQuantizer GofaissProductQuantizer;
+typedef struct GofaissScalarQuantizer GofaissScalarQuantizer;
+
+// Serialize and deserialize product and scalar quantizers the same way. The
+// reader of scalar quantizers also reports whether the quantizer is trained.
+int gofaiss_write_ProductQuantizer_stream(const GofaissProductQuantizer* pq,
+                                          uintptr_t handle);
+int gofaiss_read_ProductQuantizer_stream(uintptr_t handle,
+                                         GofaissProductQuantizer** p_out);
+int gofaiss_write_ScalarQuantizer_stream(const GofaissScalarQuantizer* sq,
+                                         uintptr_t handle);
+int gofaiss_read_ScalarQuantizer_stream(uintptr_t handle,
+                                        GofaissScalarQuantizer** p_out,
+                                        int* is_trained);
+
+// ---- Zero-copy deserialization (index_mapped.cpp) ----
+
+// Deserializes an index which references the codes and inverted lists of buf
+// in place rather than copying them, so buf must outlive it.
+int gofaiss_read_index_mapped(const uint8_t* buf, size_t size, int io_flags,
+                              FaissIndex** p_out);
+
+// ---- Structural description (describe.cpp) ----
+
+// Kinds of the nodes of an index, float and binary.
+enum {
+    GOFAISS_NODE_UNKNOWN = 0,
+    GOFAISS_NODE_FLAT,
+    GOFAISS_NODE_SQ,
+    GOFAISS_NODE_PQ,
+    GOFAISS_NODE_RABITQ,
+    GOFAISS_NODE_IVF,
+    GOFAISS_NODE_HNSW,
+    GOFAISS_NODE_IDMAP,
+    GOFAISS_NODE_IDMAP2,
+    GOFAISS_NODE_PRETRANSFORM,
+    GOFAISS_NODE_BFLAT,
+    GOFAISS_NODE_BIVF,
+    GOFAISS_NODE_BHNSW,
+    GOFAISS_NODE_BHASH,
+    GOFAISS_NODE_BMULTIHASH,
+};
+
+// Kinds of vector transforms.
+enum {
+    GOFAISS_TRANSFORM_UNKNOWN = 0,
+    GOFAISS_TRANSFORM_PCA,
+    GOFAISS_TRANSFORM_OPQ,
+    GOFAISS_TRANSFORM_RR,
+    GOFAISS_TRANSFORM_ITQ,
+    GOFAISS_TRANSFORM_L2NORM,
+};
+
+typedef struct GofaissIndexNode {
+    int kind;
+    // encoding of the vectors: that of the inverted lists for IVF indexes,
+    // and the index itself for the others.
+    int encoder;
+    size_t nlist;
+    // HNSW: number of neighbors per node above the bottom level.
+    // Hash: number of bits per hash, and number of hashes for MultiHash.
+    int m;
+    int nhash;
+    // SQ: ScalarQuantizer::QuantizerType. PQ: number and size of the
+    // sub-quantizers.
+    int sq_type;
+    size_t pq_m;
+    size_t pq_nbits;
+    // the wrapped index of IDMap and PreTransform, and the storage of HNSW.
+    // FaissIndexBinary pointers for binary indexes.
+    void* sub;
+    // the coarse quantizer of IVF indexes.
+    void* quantizer;
+    // the number of transforms of PreTransform.
+    int n_transforms;
+} GofaissIndexNode;
+
+void gofaiss_Index_describe(const FaissIndex* index, GofaissIndexNode* node);
+void gofaiss_IndexBinary_describe(const FaissIndexBinary* index,
+                                  GofaissIndexNode* node);
+
+// Describes transform i of a PreTransform index.
+void gofaiss_IndexPreTransform_transform(const FaissIndex* index, int i,
+                                         int* kind, int* d_in, int* d_out,
+                                         int* m);
+
+// ---- Clustering (clustering.cpp) ----
+
+typedef struct GofaissClusteringIterationStats {
+    float obj;
+    double time;
+    double time_search;
+    double imbalance_factor;
+    int nsplit;
+} GofaissClusteringIterationStats;
+
+size_t gofaiss_Clustering_iteration_stats_size(const FaissClustering* clustering);
+
+// Copies the statistics of the iterations of the last training into stats,
+// which must hold iteration_stats_size values.
+void gofaiss_Clustering_iteration_stats(const FaissClustering* clustering,
+                                        GofaissClusteringIterationStats* stats);
+
+// ---- Vector transforms (transform.cpp) ----
+
+// Like faiss_VectorTransform_apply_noalloc and
+// faiss_VectorTransform_reverse_transform, which do not report errors.
+int gofaiss_VectorTransform_apply(const FaissVectorTransform* vt, idx_t n,
+                                  const float* x, float* xt);
+int gofaiss_VectorTransform_reverse_transform(const FaissVectorTransform* vt,
+                                              idx_t n, const float* xt,
+                                              float* x);
+
+// Creates an IndexPreTransform applying vt before index, taking ownership of
+// both.
+int gofaiss_IndexPreTransform_new(FaissIndex** p_index,
+                                  FaissVectorTransform* vt, FaissIndex* index);
+
+// ---- Product and scalar quantizers (quantizer.cpp) ----
+
+int gofaiss_ProductQuantizer_new(GofaissProductQuantizer** p_pq, size_t d,
+                                 size_t M, size_t nbits);
+void gofaiss_ProductQuantizer_free(GofaissProductQuantizer* pq);
+size_t gofaiss_ProductQuantizer_d(const GofaissProductQuantizer* pq);
+size_t gofaiss_ProductQuantizer_M(const GofaissProductQuantizer* pq);
+size_t gofaiss_ProductQuantizer_nbits(const GofaissProductQuantizer* pq);
+size_t gofaiss_ProductQuantizer_ksub(const GofaissProductQuantizer* pq);
+size_t gofaiss_ProductQuantizer_code_size(const GofaissProductQuantizer* pq);
+int gofaiss_ProductQuantizer_train(GofaissProductQuantizer* pq, idx_t n,
+                                   const float* x);
+int gofaiss_ProductQuantizer_compute_codes(const GofaissProductQuantizer* pq,
+                                           idx_t n, const float* x,
+                                           uint8_t* codes);
+int gofaiss_ProductQuantizer_decode(const GofaissProductQuantizer* pq, idx_t n,
+                                    const uint8_t* codes, float* x);
+
+// Computes the M x ksub tables of the distances between each of the n query
+// vectors in x and the centroids of each sub-quantizer, under the L2 or
+// inner product metric.
+int gofaiss_ProductQuantizer_compute_distance_tables(
+        const GofaissProductQuantizer* pq, FaissMetricType metric, idx_t n,
+        const float* x, float* tables);
+
+// faiss::ScalarQuantizer::QuantizerType values.
+enum {
+    GOFAISS_QT_8bit = 0,
+    GOFAISS_QT_4bit,
+    GOFAISS_QT_8bit_uniform,
+    GOFAISS_QT_4bit_uniform,
+    GOFAISS_QT_fp16,
+    GOFAISS_QT_8bit_direct,
+    GOFAISS_QT_6bit,
+    GOFAISS_QT_bf16,
+};
+
+int gofaiss_ScalarQuantizer_new(GofaissScalarQuantizer** p_sq, size_t d,
+                                int qtype);
+void gofaiss_ScalarQuantizer_free(GofaissScalarQuantizer* sq);
+size_t gofaiss_ScalarQuantizer_d(const GofaissScalarQuantizer* sq);
+int gofaiss_ScalarQuantizer_qtype(const GofaissScalarQuantizer* sq);
+size_t gofaiss_ScalarQuantizer_code_size(const GofaissScalarQuantizer* sq);
+int gofaiss_ScalarQuantizer_train(GofaissScalarQuantizer* sq, idx_t n,
+                                  const float* x);
+int gofaiss_ScalarQuantizer_compute_codes(const GofaissScalarQuantizer* sq,
+                                          idx_t n, const float* x,
+                                          uint8_t* codes);
+int gofaiss_ScalarQuantizer_decode(const GofaissScalarQuantizer* sq, idx_t n,
+                                   const uint8_t* codes, float* x);
+
+// Computes the distances between query and the n vectors encoded in codes,
+// under the L2 or inner product metric, without decoding them.
+int gofaiss_ScalarQuantizer_compute_distances(
+        const GofaissScalarQuantizer* sq, FaissMetricType metric,
+        const float* query, idx_t n, const uint8_t* codes, float* distances);
+
+// ---- Typed binary indexes (binary.cpp) ----
+
+int gofaiss_IndexBinaryFlat_new(FaissIndexBinary** p_index, int d);
+
+// Creates a binary IVF index, taking ownership of quantizer.
+int gofaiss_IndexBinaryIVF_new(FaissIndexBinary** p_index,
+                               FaissIndexBinary* quantizer, int d,
+                               size_t nlist);
+int gofaiss_IndexBinaryHNSW_new(FaissIndexBinary** p_index, int d, int M);
+int gofaiss_IndexBinaryHash_new(FaissIndexBinary** p_index, int d, int b);
+int gofaiss_IndexBinaryMultiHash_new(FaissIndexBinary** p_index, int d,
+                                     int nhash, int b);
+
+// Creates a binary IDMap index, taking ownership of sub.
+int gofaiss_IndexBinaryIDMap_new(FaissIndexBinary** p_index,
+                                 FaissIndexBinary* sub);
+
+// Returns index if it is a binary HNSW index, NULL otherwise.
+FaissIndexBinary* gofaiss_IndexBinaryHNSW_cast(FaissIndexBinary* index);
+
+int gofaiss_IndexBinaryHNSW_efSearch(const FaissIndexBinary* index);
+void gofaiss_IndexBinaryHNSW_set_efSearch(FaissIndexBinary* index, int ef);
+int gofaiss_IndexBinaryHNSW_efConstruction(const FaissIndexBinary* index);
+void gofaiss_IndexBinaryHNSW_set_efConstruction(FaissIndexBinary* index,
+                                                int ef);
+
+// Returns 1 and sets the number of bits per hash, the number of hashes and
+// the number of bit flips explored by searches if index is a hash or
+// multi-hash index, and returns 0 otherwise.
+int gofaiss_IndexBinaryHash_params(const FaissIndexBinary* index, int* b,
+                                   int* nhash, int* nflip);
+
+// Returns 1 after setting nflip if index is a hash or multi-hash index, and
+// returns 0 otherwise.
+int gofaiss_IndexBinaryHash_set_nflip(FaissIndexBinary* index, int nflip);
+
+// ---- Inverted lists (invlists.cpp) ----
+
+typedef struct GofaissInvertedLists GofaissInvertedLists;
+typedef struct GofaissDirectMap GofaissDirectMap;
+
+// Return the inverted lists and the direct map of index if it is an IVF
+// index, NULL otherwise. They are owned by index.
+GofaissInvertedLists* gofaiss_IndexIVF_invlists(FaissIndex* index);
+GofaissDirectMap* gofaiss_IndexIVF_direct_map(FaissIndex* index);
+GofaissInvertedLists* gofaiss_IndexBinaryIVF_invlists(FaissIndexBinary* index);
+GofaissDirectMap* gofaiss_IndexBinaryIVF_direct_map(FaissIndexBinary* index);
+
+size_t gofaiss_InvertedLists_nlist(const GofaissInvertedLists* il);
+
+// Returns 0 for inverted lists which do not store one code per entry, such
+// as the blocks of fast-scan indexes.
+size_t gofaiss_InvertedLists_code_size(const GofaissInvertedLists* il);
+int gofaiss_InvertedLists_list_size(const GofaissInvertedLists* il,
+                                    size_t list_no, size_t* size);
+
+// Copies the IDs and the codes of list list_no, which must hold n entries,
+// into ids and codes. Either of them may be NULL.
+int gofaiss_InvertedLists_copy_list(const GofaissInvertedLists* il,
+                                    size_t list_no, size_t n, idx_t* ids,
+                                    uint8_t* codes);
+
+// faiss::DirectMap::Type, that is the maptype of SetDirectMap.
+int gofaiss_DirectMap_type(const GofaissDirectMap* dm);
+
+// Sets the list holding id and its offset in that list, or -1 for both if id
+// is not in the index.
+void gofaiss_DirectMap_lookup(const GofaissDirectMap* dm, idx_t id,
+                              idx_t* list_no, idx_t* offset);
+
+// ---- Vector updates (update.cpp) ----
+
+// Replaces the vectors of the IDs of ids which are in index and adds the
+// others, setting n_updated to the number of the former. index must be an
+// IDMap2 index, or an IVF index with a direct map. An IVF index below an
+// IDMap2 index must have a direct map too, and has its vectors updated in
+// place rather than removed and added again.
+int gofaiss_Index_update_vectors(FaissIndex* index, idx_t n, const idx_t* ids,
+                                 const float* x, size_t* n_updated);
+
+// ---- IVF centroids (centroids.cpp) ----
+
+// Reconstructs the n vectors of list list_no, which must hold n entries, into
+// x.
+int gofaiss_IndexIVF_reconstruct_list(FaissIndex* index, size_t list_no,
+                                      size_t n, float* x);
+
+// Replaces the centroids of the coarse quantizer of index with the k vectors
+// in centroids, and moves the stored vectors to the lists of their nearest
+// new centroid, reconstructing and encoding them again when the codes are
+// relative to their centroid.
+int gofaiss_IndexIVF_replace_centroids(FaissIndex* index, idx_t k,
+                                       const float* centroids);
+
+// ---- Splitting (split.cpp) ----
+
+// Splits the vectors of an IVF index into nshards new indexes, written to
+// shards, which are empty copies of index filled with the codes of the
+// vectors as is. The shard of a vector is list_shard[list] if list_shard is
+// not NULL, and the number of boundaries lower than or equal to its ID
+// otherwise, boundaries being sorted. index is left unchanged, and each
+// shard gets its own copy of the quantizer of index.
+int gofaiss_IndexIVF_split(FaissIndex* index, size_t nshards,
+                           const int* list_shard, size_t nboundaries,
+                           const idx_t* boundaries, FaissIndex** shards);
+int gofaiss_IndexBinaryIVF_split(FaissIndexBinary* index, size_t nshards,
+                                 const int* list_shard, size_t nboundaries,
+                                 const idx_t* boundaries,
+                                 FaissIndexBinary** shards);
+
+// ---- Merging (merge.cpp) ----
+
+// Moves the vectors of other into index, shifting their IDs by add_id, and
+// empties other. Unlike faiss_Index_merge_from, this supports HNSW indexes,
+// bare or wrapped in an IDMap2, whose vectors are added to the graph of index
+// one by one, provided both have flat storage. If adding the vectors of an
+// HNSW index fails, those added so far remain in index and other is left
+// unchanged, so merging again adds them twice.
+int gofaiss_Index_merge_from(FaissIndex* index, FaissIndex* other,
+                             idx_t add_id);
+
+// ---- Interruption (interrupt.cpp) ----
+
+// A token identifies a run of faiss calls made from one thread, which faiss
+// aborts with a "computation interrupted" error once the token is cancelled.
+typedef struct GofaissInterruptToken GofaissInterruptToken;
+
+GofaissInterruptToken* gofaiss_interrupt_token_new(void);
+void gofaiss_interrupt_token_free(GofaissInterruptToken* token);
+
+// Cancels token. It may be called from any thread, and does nothing once
+// gofaiss_interrupt_end has been called.
+void gofaiss_interrupt_token_cancel(GofaissInterruptToken* token);
+
+// Marks the faiss calls made from this thread between gofaiss_interrupt_begin
+// and gofaiss_interrupt_end as belonging to token. gofaiss_interrupt_end
+// returns 1 if token was cancelled in between, 0 otherwise. The faiss
+// interrupt callback consulting the tokens is installed while such calls are
+// running, and chains to the callback it replaces.
+void gofaiss_interrupt_begin(GofaissInterruptToken* token);
+int gofaiss_interrupt_end(GofaissInterruptToken* token);
+
+#ifdef __cplusplus
+}
+#endif
+
+#endif
@@ -0,0 +1,39 @@
+// Helpers shared by the C++ sources of this package.
+
+#ifndef GOFAISS_IMPL_H
+#define GOFAISS_IMPL_H
+
+#include <exception>
+#include <new>
+
+#include <faiss/impl/FaissException.h>
+
+// Defined by the faiss C API, and reported by faiss_get_last_error.
+extern thread_local std::exception_ptr faiss_last_exception;
+
+// GOFAISS_TRY and GOFAISS_CATCH wrap the body of an exported function the same
+// way the faiss C API does, so that exceptions never cross into Go and are
+// reported through faiss_get_last_error instead.
+#define GOFAISS_TRY try {
+
+#define GOFAISS_CATCH                                           \
+    }                                                           \
+    catch (faiss::FaissException & e) {                         \
+        faiss_last_exception = std::make_exception_ptr(e);      \
+        return -2;                                              \
+    }                                                           \
+    catch (std::bad_alloc & e) {                                \
+        faiss_last_exception = std::make_exception_ptr(e);      \
+        return -4;                                              \
+    }                                                           \
+    catch (std::exception & e) {                                \
+        faiss_last_exception = std::make_exception_ptr(e);      \
+        return -5;                                              \
+    }                                                           \
+    catch (...) {                                               \
+        faiss_last_exception = std::current_exception();        \
+        return -1;                                              \
+    }                                                           \
+    return 0;
+
+#endif
@@ -584,7 +584,7 @@ func CloneToCPU(gpuIndex *GPUIndexImpl) (*IndexImpl, error) {
 	); c != 0 {
 		return nil, newFaissError(ErrGPUCloneFailed, getLastError(), int(c))
 	}
-	return &IndexImpl{&faissIndex{idx: cpuIdx}}, nil
+	return &IndexImpl{newFaissIndex(cpuIdx)}, nil
 }
 
 // --------------------------------
@@ -0,0 +1,103 @@
+// HNSW indexes, see index_hnsw.go.
+
+#include "gofaiss.h"
+#include "gofaiss_impl.h"
+
+#include <faiss/IndexHNSW.h>
+
+namespace {
+
+const faiss::IndexHNSW* hnsw_index(const FaissIndex* index) {
+    return reinterpret_cast<const faiss::IndexHNSW*>(index);
+}
+
+faiss::IndexHNSW* hnsw_index(FaissIndex* index) {
+    return reinterpret_cast<faiss::IndexHNSW*>(index);
+}
+
+} // namespace
+
+int gofaiss_IndexHNSWFlat_new(
+        FaissIndex** p_index,
+        int d,
+        int M,
+        FaissMetricType metric) {
+    GOFAISS_TRY
+    *p_index = reinterpret_cast<FaissIndex*>(new faiss::IndexHNSWFlat(
+            d, M, static_cast<faiss::MetricType>(metric)));
+    GOFAISS_CATCH
+}
+
+FaissIndex* gofaiss_IndexHNSW_cast(FaissIndex* index) {
+    return reinterpret_cast<FaissIndex*>(dynamic_cast<faiss::IndexHNSW*>(
+            reinterpret_cast<faiss::Index*>(index)));
+}
+
+int gofaiss_IndexHNSW_efSearch(const FaissIndex* index) {
+    return hnsw_index(index)->hnsw.efSearch;
+}
+
+void gofaiss_IndexHNSW_set_efSearch(FaissIndex* index, int ef) {
+    hnsw_index(index)->hnsw.efSearch = ef;
+}
+
+int gofaiss_IndexHNSW_efConstruction(const FaissIndex* index) {
+    return hnsw_index(index)->hnsw.efConstruction;
+}
+
+void gofaiss_IndexHNSW_set_efConstruction(FaissIndex* index, int ef) {
+    hnsw_index(index)->hnsw.efConstruction = ef;
+}
+
+int gofaiss_IndexHNSW_max_level(const FaissIndex* index) {
+    return hnsw_index(index)->hnsw.max_level;
+}
+
+idx_t gofaiss_IndexHNSW_entry_point(const FaissIndex* index) {
+    return hnsw_index(index)->hnsw.entry_point;
+}
+
+// hnsw.levels holds the number of levels of each node.
+
+int gofaiss_IndexHNSW_level(const FaissIndex* index, idx_t id) {
+    return hnsw_index(index)->hnsw.levels[id] - 1;
+}
+
+void gofaiss_IndexHNSW_levels(const FaissIndex* index, int* levels) {
+    const faiss::HNSW& hnsw = hnsw_index(index)->hnsw;
+    for (size_t i = 0; i < hnsw.levels.size(); i++) {
+        levels[i] = hnsw.levels[i] - 1;
+    }
+}
+
+int gofaiss_IndexHNSW_nb_neighbors(const FaissIndex* index, int level) {
+    return hnsw_index(index)->hnsw.nb_neighbors(level);
+}
+
+void gofaiss_IndexHNSW_neighbors(
+        const FaissIndex* index,
+        idx_t id,
+        int level,
+        idx_t* neighbors,
+        size_t* n) {
+    const faiss::HNSW& hnsw = hnsw_index(index)->hnsw;
+    size_t begin, end;
+    hnsw.neighbor_range(id, level, &begin, &end);
+    *n = 0;
+    // the neighbor lists are padded with -1.
+    for (size_t i = begin; i < end && hnsw.neighbors[i] >= 0; i++) {
+        neighbors[(*n)++] = hnsw.neighbors[i];
+    }
+}
+
+int gofaiss_SearchParametersHNSW_new(
+        FaissSearchParameters** p_sp,
+        FaissIDSelector* sel,
+        int efSearch) {
+    GOFAISS_TRY
+    auto sp = new faiss::SearchParametersHNSW();
+    sp->sel = reinterpret_cast<faiss::IDSelector*>(sel);
+    sp->efSearch = efSearch;
+    *p_sp = reinterpret_cast<FaissSearchParameters*>(sp);
+    GOFAISS_CATCH
+}
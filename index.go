@@ -12,8 +12,11 @@ package faiss
 */
 import "C"
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"reflect"
+	"runtime"
 	"sort"
 	"unsafe"
 )
@@ -49,6 +52,37 @@ type Index interface {
 	// set the number of probes for IVF indexes
 	SetNProbe(nprobe int32)
 
+	// InvertedLists returns a view onto the inverted lists of IVF indexes.
+	InvertedLists() (*InvertedLists, error)
+
+	// Returns true if the index is an HNSW index.
+	IsHNSWIndex() bool
+
+	// Returns the HNSW parameters efSearch and efConstruction for HNSW
+	// indexes.
+	HNSWParams() (efSearch, efConstruction int)
+
+	// set the size of the candidate list explored by searches of HNSW
+	// indexes, trading latency for recall. Can be overridden per query with
+	// the "hnsw_ef_search" search param.
+	SetEfSearch(ef int) error
+
+	// set the size of the candidate list explored when adding vectors to
+	// HNSW indexes, trading indexing time for graph quality.
+	SetEfConstruction(ef int) error
+
+	// Applicable only to HNSW indexes: Returns the number of nodes present on
+	// each level of the graph, starting from the bottom level, which holds
+	// all of them.
+	HNSWLevelCounts() ([]int64, error)
+
+	// Applicable only to HNSW indexes: Returns the highest level of node id.
+	HNSWNodeLevel(id int64) (int, error)
+
+	// Applicable only to HNSW indexes: Returns the neighbors of node id on the
+	// given level, which must not be above the highest level of the node.
+	HNSWNeighbors(id int64, level int) ([]int64, error)
+
 	// MetricType returns the metric type of the index.
 	MetricType() int
 
@@ -58,9 +92,24 @@ type Index interface {
 	// Add adds vectors to the index.
 	Add(x []float32) error
 
+	// AddContext is like Add, but adds the vectors in batches and stops early,
+	// returning an error wrapping ctx.Err(), once ctx is done.
+	AddContext(ctx context.Context, x []float32) error
+
 	// AddWithIDs is like Add, but stores xids instead of sequential IDs.
 	AddWithIDs(x []float32, xids []int64) error
 
+	// UpdateVectors replaces the vectors of the IDs of ids which are in the
+	// index with those in x, and adds the others, in a single call. Applicable
+	// to IDMap2 indexes, and to IVF indexes with a direct map, see
+	// SetDirectMap, which IVF indexes wrapped in an IDMap2 need as well:
+	// ErrNoDirectMap is returned otherwise. IVF indexes update the vectors in
+	// place. Returns the number of updated and inserted IDs. For IDMap2
+	// indexes wrapping another lossy index such as SQ or PQ, a failed update
+	// restores the replaced vectors as reconstructed from their codes,
+	// re-encoding them.
+	UpdateVectors(ids []int64, x []float32) (updated, inserted int, err error)
+
 	// Returns true if the index is an IVF index.
 	IsIVFIndex() bool
 
@@ -100,7 +149,22 @@ type Index interface {
 	// - params is a JSON object that can contain additional search parameters specific to the index type, such as IVF search parameters.
 	SearchWithOptions(x []float32, k int64, sel Selector, params json.RawMessage) (distances []float32, labels []int64, err error)
 
+	// SearchInto and SearchWithOptionsInto are like Search and
+	// SearchWithOptions, but write the results into the caller supplied
+	// distances and labels, which must hold at least n*k values each for n
+	// query vectors. Only the first n*k values are written.
+	SearchInto(x []float32, k int64, distances []float32, labels []int64) error
+	SearchWithOptionsInto(x []float32, k int64, sel Selector, params json.RawMessage, distances []float32, labels []int64) error
+
+	// SearchContext and SearchWithOptionsContext are like Search and
+	// SearchWithOptions, but search the queries in batches and stop early,
+	// aborting the batch under way in faiss and returning an error wrapping
+	// ctx.Err(), once ctx is done.
+	SearchContext(ctx context.Context, x []float32, k int64) (distances []float32, labels []int64, err error)
+	SearchWithOptionsContext(ctx context.Context, x []float32, k int64, sel Selector, params json.RawMessage) (distances []float32, labels []int64, err error)
+
 	// Applicable only to IVF indexes: Search clusters whose IDs are in eligibleCentroidIDs
+	// for the single query vector x.
 	SearchClustersFromIVFIndex(eligibleCentroidIDs []int64, centroidDis []float32, centroidsToProbe int,
 		x []float32, k int64, include Selector, params json.RawMessage) ([]float32, []int64, error)
 
@@ -108,12 +172,28 @@ type Index interface {
 
 	ReconstructBatch(keys []int64, recons []float32) ([]float32, error)
 
+	// MergeFrom moves the vectors of other into idx, leaving other empty, so
+	// both must be writable: ErrIndexReadOnly is returned otherwise, e.g. for
+	// an index read with ReadIndexFromMappedBuffer.
+	// Both indexes must have the same dimension and metric, and be IVF, SQ,
+	// flat, HNSW or IDMap2 indexes wrapping the same kind of index. add_id is
+	// added to the IDs of the moved vectors, which must be 0 for flat, SQ and
+	// HNSW indexes, whose IDs are sequential.
+	// HNSW indexes must have flat storage, their vectors being added to the
+	// graph of idx one by one. If that fails partway, the vectors added so
+	// far remain in idx while other is left unchanged, so retrying the merge
+	// duplicates them.
 	MergeFrom(other Index, add_id int64) error
 
 	// RangeSearch queries the index with the vectors in x.
 	// Returns all vectors with distance < radius.
 	RangeSearch(x []float32, radius float32) (*RangeSearchResult, error)
 
+	// RangeSearchContext is like RangeSearch, but searches the queries in
+	// batches and stops early, aborting the batch under way in faiss and
+	// returning an error wrapping ctx.Err(), once ctx is done.
+	RangeSearchContext(ctx context.Context, x []float32, radius float32) (*RangeSearchResult, error)
+
 	// DistCompute computes the distance between the query vector and the vectors specified by ids.
 	DistCompute(x []float32, labels []int64) ([]float32, error)
 
@@ -124,7 +204,9 @@ type Index interface {
 	// Returns the number of elements removed and error.
 	RemoveIDs(sel *IDSelector) (int, error)
 
-	// Close frees the memory used by the index.
+	// Close frees the memory used by the index. It is safe to call Close more
+	// than once; any other method called after Close returns ErrIndexClosed
+	// (or a zero value, for methods that cannot return an error).
 	Close()
 
 	// Size estimates the memory footprint of the index in bytes,
@@ -134,24 +216,95 @@ type Index interface {
 	// cPtr returns a pointer to the underlying C index struct.
 	cPtr() *C.FaissIndex
 
+	// impl returns the underlying faissIndex.
+	impl() *faissIndex
+
 	// set the quantizers from a source index into this index, applicable only
 	// for IVF indexes
 	SetQuantizers(source Index) error
 
+	// Applicable only to IVF indexes: replaces the centroids of the coarse
+	// quantizer with centroids, which holds one or more vectors of dimension
+	// D(), and moves the stored vectors to the lists of their nearest new
+	// centroid.
+	ReplaceCentroids(centroids []float32) error
+
+	// Applicable only to IVF indexes: splits the lists holding more than
+	// maxListSize vectors and merges the smallest ones into their neighbors,
+	// see ReplaceCentroids. Returns the number of split and merged lists.
+	Rebalance(maxListSize int) (split, merged int, err error)
+
+	// Applicable only to IVF indexes: split the index into new IVF indexes
+	// with copies of its quantizer, either by ID, shard i holding the IDs in
+	// [boundaries[i-1], boundaries[i]), or by list, shard i holding the
+	// vectors of the lists in groups[i]. The codes are copied as is.
+	SplitByIDRange(boundaries []int64) ([]Index, error)
+	SplitByLists(groups [][]int64) ([]Index, error)
+
 	// CodeSize returns the size of the produced codes in bytes.
 	CodeSize() (uint64, error)
 }
 
 type faissIndex struct {
 	idx *C.FaissIndex
+
+	// cleanup frees idx if the index is garbage collected without Close.
+	cleanup runtime.Cleanup
+
+	// parent is set when idx points into memory owned by another index (such
+	// as the sub-index of an IDMap2), in which case idx is never freed here.
+	parent *faissIndex
+
+	// readOnly is set when idx references memory it does not own, see
+	// ReadIndexFromMappedBuffer, which pinner keeps in place until Close.
+	readOnly bool
+	pinner   *runtime.Pinner
+}
+
+// newFaissIndex wraps an index allocated on the C heap, taking ownership of it.
+func newFaissIndex(ptr *C.FaissIndex) *faissIndex {
+	idx := &faissIndex{idx: ptr}
+	idx.cleanup = addLeakCleanup(idx, "faiss index", func() {
+		C.faiss_Index_free(ptr)
+	})
+	return idx
+}
+
+// closed returns true if the index, or the index owning it, has been closed.
+func (idx *faissIndex) closed() bool {
+	return idx.idx == nil || (idx.parent != nil && idx.parent.closed())
 }
 
+// writable returns ErrIndexClosed or ErrIndexReadOnly if the index cannot be
+// modified.
+func (idx *faissIndex) writable() error {
+	if idx.closed() {
+		return ErrIndexClosed
+	}
+	if idx.readOnly || (idx.parent != nil && idx.parent.writable() != nil) {
+		return ErrIndexReadOnly
+	}
+	return nil
+}
+
+// cPtr returns nil once the index has been closed.
 func (idx *faissIndex) cPtr() *C.FaissIndex {
+	if idx.closed() {
+		return nil
+	}
 	return idx.idx
 }
 
+func (idx *faissIndex) impl() *faissIndex {
+	return idx
+}
+
 func (idx *faissIndex) Size() uint64 {
 	rv := reflectStaticSizeFaissIndex
+	if idx.closed() {
+		return rv
+	}
+	defer runtime.KeepAlive(idx)
 	var size C.size_t
 	if code := C.faiss_Index_size(idx.idx, &size); code == 0 {
 		rv += uint64(size)
@@ -160,10 +313,18 @@ func (idx *faissIndex) Size() uint64 {
 }
 
 func (idx *faissIndex) D() int {
+	if idx.closed() {
+		return 0
+	}
+	defer runtime.KeepAlive(idx)
 	return int(C.faiss_Index_d(idx.idx))
 }
 
 func (idx *faissIndex) CodeSize() (uint64, error) {
+	if idx.closed() {
+		return 0, ErrIndexClosed
+	}
+	defer runtime.KeepAlive(idx)
 	var size C.size_t
 	if c := C.faiss_Index_sa_code_size(idx.idx, &size); c != 0 {
 		return 0, newFaissError(ErrInspectIndexFailed, getLastError(), int(c))
@@ -172,19 +333,38 @@ func (idx *faissIndex) CodeSize() (uint64, error) {
 }
 
 func (idx *faissIndex) IsTrained() bool {
+	if idx.closed() {
+		return false
+	}
+	defer runtime.KeepAlive(idx)
 	return C.faiss_Index_is_trained(idx.idx) != 0
 }
 
 func (idx *faissIndex) Ntotal() int64 {
+	if idx.closed() {
+		return 0
+	}
+	defer runtime.KeepAlive(idx)
 	return int64(C.faiss_Index_ntotal(idx.idx))
 }
 
 func (idx *faissIndex) MetricType() int {
+	if idx.closed() {
+		return 0
+	}
+	defer runtime.KeepAlive(idx)
 	return int(C.faiss_Index_metric_type(idx.idx))
 }
 
 func (idx *faissIndex) Train(x []float32) error {
-	n := len(x) / idx.D()
+	if err := idx.writable(); err != nil {
+		return err
+	}
+	defer runtime.KeepAlive(idx)
+	n, err := validateVectors(x, idx.D())
+	if err != nil {
+		return err
+	}
 	if c := C.faiss_Index_train(idx.idx, C.idx_t(n), (*C.float)(&x[0])); c != 0 {
 		return newFaissError(ErrTrainFailed, getLastError(), int(c))
 	}
@@ -192,7 +372,14 @@ func (idx *faissIndex) Train(x []float32) error {
 }
 
 func (idx *faissIndex) Add(x []float32) error {
-	n := len(x) / idx.D()
+	if err := idx.writable(); err != nil {
+		return err
+	}
+	defer runtime.KeepAlive(idx)
+	n, err := validateVectors(x, idx.D())
+	if err != nil {
+		return err
+	}
 	if c := C.faiss_Index_add(idx.idx, C.idx_t(n), (*C.float)(&x[0])); c != 0 {
 		return newFaissError(ErrAddFailed, getLastError(), int(c))
 	}
@@ -200,6 +387,10 @@ func (idx *faissIndex) Add(x []float32) error {
 }
 
 func (idx *faissIndex) ObtainClusterVectorCountsFromIVFIndex(includedVectors Selector, nlist int) ([]int64, error) {
+	if idx.closed() {
+		return nil, ErrIndexClosed
+	}
+	defer runtime.KeepAlive(idx)
 	// Applicable only to IVF indexes
 	ivfPtr := C.faiss_IndexIVF_cast(idx.cPtr())
 	if ivfPtr == nil {
@@ -218,18 +409,26 @@ func (idx *faissIndex) ObtainClusterVectorCountsFromIVFIndex(includedVectors Sel
 	// Calling the C function to populate listCount
 	// with the count of vectors per cluster, considering only
 	// the vectors specified in the include selector.
-	if c := C.faiss_IndexIVF_list_vector_count(
+	c := C.faiss_IndexIVF_list_vector_count(
 		ivfPtr,
 		(*C.idx_t)(unsafe.Pointer(&listCount[0])),
 		C.size_t(nlist),
 		params.sp,
-	); c != 0 {
+	)
+	if err := selectorError(includedVectors); err != nil {
+		return nil, err
+	}
+	if c != 0 {
 		return nil, newFaissError(ErrInspectIndexFailed, getLastError(), int(c))
 	}
 	return listCount, nil
 }
 
 func (idx *faissIndex) IsIVFIndex() bool {
+	if idx.closed() {
+		return false
+	}
+	defer runtime.KeepAlive(idx)
 	if ivfIdx := C.faiss_IndexIVF_cast(idx.cPtr()); ivfIdx == nil {
 		return false
 	}
@@ -237,16 +436,31 @@ func (idx *faissIndex) IsIVFIndex() bool {
 }
 
 func (idx *faissIndex) HasRaBitQ() bool {
+	if idx.closed() {
+		return false
+	}
+	defer runtime.KeepAlive(idx)
 	return C.faiss_IndexIVF_has_RaBitQ(idx.idx) == 0
 }
 
 func (idx *faissIndex) ObtainClustersWithDistancesFromIVFIndex(x []float32, includedCentroids Selector, numCentroids int64) (
 	[]int64, []float32, error) {
+	if idx.closed() {
+		return nil, nil, ErrIndexClosed
+	}
+	defer runtime.KeepAlive(idx)
 	// Applicable only to IVF indexes
 	ivfPtr := C.faiss_IndexIVF_cast(idx.cPtr())
 	if ivfPtr == nil {
 		return nil, nil, ErrNotIVFIndex
 	}
+	n, err := validateVectors(x, idx.D())
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := validateK(numCentroids); err != nil {
+		return nil, nil, err
+	}
 	params, err := NewStandardSearchParams(includedCentroids)
 	if err != nil {
 		return nil, nil, err
@@ -254,12 +468,10 @@ func (idx *faissIndex) ObtainClustersWithDistancesFromIVFIndex(x []float32, incl
 	defer params.Delete()
 
 	// Populate these with the centroids and their distances.
-	centroids := make([]int64, numCentroids)
-	centroidDistances := make([]float32, numCentroids)
-
-	n := len(x) / idx.D()
+	centroids := make([]int64, int64(n)*numCentroids)
+	centroidDistances := make([]float32, int64(n)*numCentroids)
 
-	if c := C.faiss_IndexIVF_search_closest_eligible_centroids(
+	c := C.faiss_IndexIVF_search_closest_eligible_centroids(
 		ivfPtr,
 		(C.idx_t)(n),
 		(*C.float)(&x[0]),
@@ -267,7 +479,11 @@ func (idx *faissIndex) ObtainClustersWithDistancesFromIVFIndex(x []float32, incl
 		(*C.float)(&centroidDistances[0]),
 		(*C.idx_t)(&centroids[0]),
 		params.sp,
-	); c != 0 {
+	)
+	if err := selectorError(includedCentroids); err != nil {
+		return nil, nil, err
+	}
+	if c != 0 {
 		return nil, nil, newFaissError(ErrSearchFailed, getLastError(), int(c))
 	}
 
@@ -276,6 +492,10 @@ func (idx *faissIndex) ObtainClustersWithDistancesFromIVFIndex(x []float32, incl
 
 func (idx *faissIndex) ObtainKCentroidCardinalitiesFromIVFIndex(limit int, descending bool) (
 	[]uint64, [][]float32, error) {
+	if idx.closed() {
+		return nil, nil, ErrIndexClosed
+	}
+	defer runtime.KeepAlive(idx)
 	if limit <= 0 {
 		return nil, nil, nil
 	}
@@ -340,6 +560,10 @@ func getIndicesOfKCentroidCardinalities(cardinalities []C.size_t, k int, descend
 	return indices[:k]
 }
 func (idx *faissIndex) Nlist() int {
+	if idx.closed() {
+		return 0
+	}
+	defer runtime.KeepAlive(idx)
 	ivfPtr := C.faiss_IndexIVF_cast(idx.cPtr())
 	if ivfPtr == nil {
 		return 0
@@ -349,6 +573,10 @@ func (idx *faissIndex) Nlist() int {
 
 func (idx *faissIndex) SearchClustersFromIVFIndex(eligibleCentroidIDs []int64, centroidDis []float32, centroidsToProbe int,
 	x []float32, k int64, include Selector, params json.RawMessage) ([]float32, []int64, error) {
+	if idx.closed() {
+		return nil, nil, ErrIndexClosed
+	}
+	defer runtime.KeepAlive(idx)
 	// Applicable only to IVF indexes
 	ivfPtr := C.faiss_IndexIVF_cast(idx.cPtr())
 	if ivfPtr == nil {
@@ -359,6 +587,25 @@ func (idx *faissIndex) SearchClustersFromIVFIndex(eligibleCentroidIDs []int64, c
 	if include == nil {
 		return nil, nil, ErrSelectorNil
 	}
+	n, err := validateVectors(x, idx.D())
+	if err != nil {
+		return nil, nil, err
+	}
+	// faiss reads nprobe preassigned lists per query, and eligibleCentroidIDs
+	// and centroidDis hold those of a single query.
+	if n != 1 {
+		return nil, nil, fmt.Errorf("%w: x must hold a single query of %d values, got %d",
+			ErrDimensionMismatch, idx.D(), len(x))
+	}
+	if err := validateK(k); err != nil {
+		return nil, nil, err
+	}
+	if len(eligibleCentroidIDs) == 0 {
+		return nil, nil, ErrEmptyInput
+	}
+	if err := validateBuffer(len(centroidDis), len(eligibleCentroidIDs)); err != nil {
+		return nil, nil, err
+	}
 	// create a temporary search params object to set nprobe, this will override
 	// the nprobe and the nlist set at index time, this will allow the search to
 	// probe only the clusters specified in eligibleCentroidIDs
@@ -376,8 +623,6 @@ func (idx *faissIndex) SearchClustersFromIVFIndex(eligibleCentroidIDs []int64, c
 	}
 	defer searchParams.Delete()
 
-	n := len(x) / idx.D()
-
 	distances := make([]float32, int64(n)*k)
 	labels := make([]int64, int64(n)*k)
 	// Adjust the slices to match the effective nprobe set in searchParams, as the input
@@ -389,7 +634,7 @@ func (idx *faissIndex) SearchClustersFromIVFIndex(eligibleCentroidIDs []int64, c
 	eligibleCentroidIDs = eligibleCentroidIDs[:effectiveNprobe]
 	centroidDis = centroidDis[:effectiveNprobe]
 
-	if c := C.faiss_IndexIVF_search_preassigned_with_params(
+	c := C.faiss_IndexIVF_search_preassigned_with_params(
 		ivfPtr,
 		(C.idx_t)(n),
 		(*C.float)(&x[0]),
@@ -400,7 +645,11 @@ func (idx *faissIndex) SearchClustersFromIVFIndex(eligibleCentroidIDs []int64, c
 		(*C.idx_t)(&labels[0]),
 		(C.int)(0),
 		searchParams.sp,
-	); c != 0 {
+	)
+	if err := selectorError(include); err != nil {
+		return nil, nil, err
+	}
+	if c != 0 {
 		return nil, nil, newFaissError(ErrSearchFailed, getLastError(), int(c))
 	}
 
@@ -408,7 +657,17 @@ func (idx *faissIndex) SearchClustersFromIVFIndex(eligibleCentroidIDs []int64, c
 }
 
 func (idx *faissIndex) AddWithIDs(x []float32, xids []int64) error {
-	n := len(x) / idx.D()
+	if err := idx.writable(); err != nil {
+		return err
+	}
+	defer runtime.KeepAlive(idx)
+	n, err := validateVectors(x, idx.D())
+	if err != nil {
+		return err
+	}
+	if err := validateIDs(xids, n); err != nil {
+		return err
+	}
 	if c := C.faiss_Index_add_with_ids(
 		idx.idx,
 		C.idx_t(n),
@@ -426,9 +685,36 @@ func (idx *faissIndex) AddWithIDs(x []float32, xids []int64) error {
 func (idx *faissIndex) Search(x []float32, k int64) (
 	distances []float32, labels []int64, err error,
 ) {
-	n := len(x) / idx.D()
+	if idx.closed() {
+		return nil, nil, ErrIndexClosed
+	}
+	n, err := validateVectors(x, idx.D())
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := validateK(k); err != nil {
+		return nil, nil, err
+	}
 	distances = make([]float32, int64(n)*k)
 	labels = make([]int64, int64(n)*k)
+	if err = idx.SearchInto(x, k, distances, labels); err != nil {
+		return nil, nil, err
+	}
+	return distances, labels, nil
+}
+
+// Always use SearchWithOptionsInto for indexes involving RaBitQ, for the
+// same reason as with Search.
+func (idx *faissIndex) SearchInto(x []float32, k int64, distances []float32,
+	labels []int64) error {
+	if idx.closed() {
+		return ErrIndexClosed
+	}
+	defer runtime.KeepAlive(idx)
+	n, err := validateSearchInto(x, idx.D(), k, len(distances), len(labels))
+	if err != nil {
+		return err
+	}
 	if c := C.faiss_Index_search(
 		idx.idx,
 		C.idx_t(n),
@@ -437,20 +723,47 @@ func (idx *faissIndex) Search(x []float32, k int64) (
 		(*C.float)(&distances[0]),
 		(*C.idx_t)(&labels[0]),
 	); c != 0 {
-		err = newFaissError(ErrSearchFailed, getLastError(), int(c))
+		return newFaissError(ErrSearchFailed, getLastError(), int(c))
 	}
-
-	return
+	return nil
 }
 
 func (idx *faissIndex) SearchWithOptions(x []float32, k int64, sel Selector, params json.RawMessage) ([]float32, []int64, error) {
+	if idx.closed() {
+		return nil, nil, ErrIndexClosed
+	}
+	n, err := validateVectors(x, idx.D())
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := validateK(k); err != nil {
+		return nil, nil, err
+	}
+	distances := make([]float32, int64(n)*k)
+	labels := make([]int64, int64(n)*k)
+	if err := idx.SearchWithOptionsInto(x, k, sel, params, distances, labels); err != nil {
+		return nil, nil, err
+	}
+	return distances, labels, nil
+}
+
+func (idx *faissIndex) SearchWithOptionsInto(x []float32, k int64, sel Selector,
+	params json.RawMessage, distances []float32, labels []int64) error {
+	if idx.closed() {
+		return ErrIndexClosed
+	}
+	defer runtime.KeepAlive(idx)
 	if sel == nil && params == nil && !idx.HasRaBitQ() {
-		return idx.Search(x, k)
+		return idx.SearchInto(x, k, distances, labels)
 	}
-	return idx.searchWithOptions(x, k, sel, params)
+	return idx.searchWithOptionsInto(x, k, sel, params, distances, labels)
 }
 
 func (idx *faissIndex) Reconstruct(key int64) (recons []float32, err error) {
+	if idx.closed() {
+		return nil, ErrIndexClosed
+	}
+	defer runtime.KeepAlive(idx)
 	rv := make([]float32, idx.D())
 	if c := C.faiss_Index_reconstruct(
 		idx.idx,
@@ -464,6 +777,16 @@ func (idx *faissIndex) Reconstruct(key int64) (recons []float32, err error) {
 }
 
 func (idx *faissIndex) ReconstructBatch(keys []int64, recons []float32) ([]float32, error) {
+	if idx.closed() {
+		return nil, ErrIndexClosed
+	}
+	defer runtime.KeepAlive(idx)
+	if len(keys) == 0 {
+		return nil, ErrEmptyInput
+	}
+	if err := validateBuffer(len(recons), len(keys)*idx.D()); err != nil {
+		return nil, err
+	}
 	var err error
 	n := int64(len(keys))
 	if c := C.faiss_Index_reconstruct_batch(
@@ -478,29 +801,17 @@ func (idx *faissIndex) ReconstructBatch(keys []int64, recons []float32) ([]float
 	return recons, err
 }
 
-func (idx *faissIndex) MergeFrom(other Index, add_id int64) (err error) {
-	// currrently we support the mergeFrom API only for IVF and SQ indexes
-	// todo: support on Flat index as well
-	if !(idx.IsIVFIndex() && other.IsIVFIndex()) &&
-		!(idx.IsSQIndex() && other.IsSQIndex()) {
-		return ErrMergeFromNotSupported
-	}
-
-	if c := C.faiss_Index_merge_from(
-		idx.cPtr(),
-		other.cPtr(),
-		(C.idx_t)(add_id),
-	); c != 0 {
-		err = newFaissError(ErrMergeFromFailed, getLastError(), int(c))
-	}
-
-	return err
-}
-
 func (idx *faissIndex) RangeSearch(x []float32, radius float32) (
 	*RangeSearchResult, error,
 ) {
-	n := len(x) / idx.D()
+	if idx.closed() {
+		return nil, ErrIndexClosed
+	}
+	defer runtime.KeepAlive(idx)
+	n, err := validateVectors(x, idx.D())
+	if err != nil {
+		return nil, err
+	}
 	var rsr *C.FaissRangeSearchResult
 	if c := C.faiss_RangeSearchResult_new(&rsr, C.idx_t(n)); c != 0 {
 		return nil, newFaissError(ErrSearchFailed, getLastError(), int(c))
@@ -512,12 +823,27 @@ func (idx *faissIndex) RangeSearch(x []float32, radius float32) (
 		C.float(radius),
 		rsr,
 	); c != 0 {
+		C.faiss_RangeSearchResult_free(rsr)
 		return nil, newFaissError(ErrSearchFailed, getLastError(), int(c))
 	}
-	return &RangeSearchResult{rsr}, nil
+	return newRangeSearchResult(rsr, idx.MetricType()), nil
 }
 
 func (idx *faissIndex) DistCompute(queryData []float32, ids []int64) ([]float32, error) {
+	if idx.closed() {
+		return nil, ErrIndexClosed
+	}
+	defer runtime.KeepAlive(idx)
+	// exactly one query vector is compared against all of ids
+	if n, err := validateVectors(queryData, idx.D()); err != nil {
+		return nil, err
+	} else if n != 1 {
+		return nil, fmt.Errorf("%w: expected a single query vector, got %d",
+			ErrDimensionMismatch, n)
+	}
+	if len(ids) == 0 {
+		return nil, ErrEmptyInput
+	}
 	distances := make([]float32, len(ids))
 	if c := C.faiss_Index_dist_compute(idx.idx, (*C.float)(&queryData[0]),
 		(*C.idx_t)(&ids[0]), (C.size_t)(len(ids)), (*C.float)(&distances[0])); c != 0 {
@@ -528,6 +854,10 @@ func (idx *faissIndex) DistCompute(queryData []float32, ids []int64) ([]float32,
 }
 
 func (idx *faissIndex) Reset() error {
+	if err := idx.writable(); err != nil {
+		return err
+	}
+	defer runtime.KeepAlive(idx)
 	if c := C.faiss_Index_reset(idx.idx); c != 0 {
 		return newFaissError(ErrResetIndexFailed, getLastError(), int(c))
 	}
@@ -535,30 +865,57 @@ func (idx *faissIndex) Reset() error {
 }
 
 func (idx *faissIndex) RemoveIDs(sel *IDSelector) (int, error) {
+	if err := idx.writable(); err != nil {
+		return 0, err
+	}
+	if sel == nil {
+		return 0, ErrSelectorNil
+	}
+	if sel.sel == nil {
+		return 0, ErrSelectorClosed
+	}
+	defer runtime.KeepAlive(idx)
+	defer runtime.KeepAlive(sel)
 	var nRemoved C.size_t
-	if c := C.faiss_Index_remove_ids(idx.idx, sel.sel, &nRemoved); c != 0 {
+	c := C.faiss_Index_remove_ids(idx.idx, sel.sel, &nRemoved)
+	if err := selectorError(sel); err != nil {
+		return int(nRemoved), err
+	}
+	if c != 0 {
 		return 0, newFaissError(ErrRemoveIDsFailed, getLastError(), int(c))
 	}
 	return int(nRemoved), nil
 }
 
 func (idx *faissIndex) Close() {
-	C.faiss_Index_free(idx.idx)
+	if idx.idx == nil {
+		return
+	}
+	if idx.parent == nil {
+		idx.cleanup.Stop()
+		C.faiss_Index_free(idx.idx)
+	}
+	if idx.pinner != nil {
+		idx.pinner.Unpin()
+		idx.pinner = nil
+	}
+	idx.idx = nil
 }
 
-func (idx *faissIndex) searchWithOptions(x []float32, k int64, sel Selector, params json.RawMessage) ([]float32, []int64, error) {
+func (idx *faissIndex) searchWithOptionsInto(x []float32, k int64, sel Selector,
+	params json.RawMessage, distances []float32, labels []int64) error {
+	n, err := validateSearchInto(x, idx.D(), k, len(distances), len(labels))
+	if err != nil {
+		return err
+	}
 	// Build a search params object to contain either the selector, the additional params, or both.
 	searchParams, err := NewSearchParams(idx, params, sel, nil)
 	if err != nil {
-		return nil, nil, err
+		return err
 	}
 	defer searchParams.Delete()
 
-	n := len(x) / idx.D()
-	distances := make([]float32, int64(n)*k)
-	labels := make([]int64, int64(n)*k)
-
-	if c := C.faiss_Index_search_with_params(
+	c := C.faiss_Index_search_with_params(
 		idx.idx,
 		C.idx_t(n),
 		(*C.float)(&x[0]),
@@ -566,10 +923,14 @@ func (idx *faissIndex) searchWithOptions(x []float32, k int64, sel Selector, par
 		searchParams.sp,
 		(*C.float)(&distances[0]),
 		(*C.idx_t)(&labels[0]),
-	); c != 0 {
-		return nil, nil, newFaissError(ErrSearchFailed, getLastError(), int(c))
+	)
+	if err := selectorError(sel); err != nil {
+		return err
 	}
-	return distances, labels, nil
+	if c != 0 {
+		return newFaissError(ErrSearchFailed, getLastError(), int(c))
+	}
+	return nil
 }
 
 // -----------------------------------------------------------------------------
@@ -577,16 +938,40 @@ func (idx *faissIndex) searchWithOptions(x []float32, k int64, sel Selector, par
 // RangeSearchResult is the result of a range search.
 type RangeSearchResult struct {
 	rsr *C.FaissRangeSearchResult
+	// metric of the searched index, which orders the results of Query.
+	metric int
+
+	// cleanup frees rsr if the result is garbage collected without Delete.
+	cleanup runtime.Cleanup
+}
+
+// newRangeSearchResult wraps a range search result allocated on the C heap
+// by a search of an index with the given metric, taking ownership of it.
+func newRangeSearchResult(rsr *C.FaissRangeSearchResult, metric int) *RangeSearchResult {
+	r := &RangeSearchResult{rsr: rsr, metric: metric}
+	r.cleanup = addLeakCleanup(r, "faiss range search result", func() {
+		C.faiss_RangeSearchResult_free(rsr)
+	})
+	return r
 }
 
 // Nq returns the number of queries.
 func (r *RangeSearchResult) Nq() int {
+	if r.rsr == nil {
+		return 0
+	}
+	defer runtime.KeepAlive(r)
 	return int(C.faiss_RangeSearchResult_nq(r.rsr))
 }
 
 // Lims returns a slice containing start and end indices for queries in the
 // distances and labels slices returned by Labels.
+// The returned slice points into C memory owned by r and is only valid until
+// Delete.
 func (r *RangeSearchResult) Lims() []int {
+	if r.rsr == nil {
+		return nil
+	}
 	var lims *C.size_t
 	C.faiss_RangeSearchResult_lims(r.rsr, &lims)
 	length := r.Nq() + 1
@@ -595,7 +980,12 @@ func (r *RangeSearchResult) Lims() []int {
 
 // Labels returns the unsorted IDs and respective distances for each query.
 // The result for query i is labels[lims[i]:lims[i+1]].
+// The returned slices point into C memory owned by r and are only valid until
+// Delete.
 func (r *RangeSearchResult) Labels() (labels []int64, distances []float32) {
+	if r.rsr == nil {
+		return nil, nil
+	}
 	lims := r.Lims()
 	length := lims[len(lims)-1]
 	var clabels *C.idx_t
@@ -606,9 +996,15 @@ func (r *RangeSearchResult) Labels() (labels []int64, distances []float32) {
 	return
 }
 
-// Delete frees the memory associated with r.
+// Delete frees the memory associated with r. It is safe to call Delete more
+// than once.
 func (r *RangeSearchResult) Delete() {
+	if r == nil || r.rsr == nil {
+		return
+	}
+	r.cleanup.Stop()
 	C.faiss_RangeSearchResult_free(r.rsr)
+	r.rsr = nil
 }
 
 // IndexImpl is an abstract structure for an index.
@@ -621,12 +1017,12 @@ type IndexImpl struct {
 func IndexFactory(d int, description string, metric int) (*IndexImpl, error) {
 	cdesc := C.CString(description)
 	defer C.free(unsafe.Pointer(cdesc))
-	var idx faissIndex
-	c := C.faiss_index_factory(&idx.idx, C.int(d), cdesc, C.FaissMetricType(metric))
+	var idx *C.FaissIndex
+	c := C.faiss_index_factory(&idx, C.int(d), cdesc, C.FaissMetricType(metric))
 	if c != 0 {
 		return nil, newFaissError(ErrCreateIndexFailed, getLastError(), int(c))
 	}
-	return &IndexImpl{&idx}, nil
+	return &IndexImpl{newFaissIndex(idx)}, nil
 }
 
 func SetOMPThreads(n uint) {
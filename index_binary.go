@@ -8,11 +8,17 @@ package faiss
 #include <faiss/c_api/IndexBinaryIVF_c_ex.h>
 #include <faiss/c_api/IndexBinaryIVF_c.h>
 #include <faiss/c_api/index_factory_c.h>
+#include <faiss/c_api/impl/AuxIndexStructures_c.h>
 */
 import "C"
 import (
+	"context"
+	"encoding/binary"
 	"encoding/json"
+	"fmt"
+	"math/bits"
 	"reflect"
+	"runtime"
 	"unsafe"
 )
 
@@ -33,6 +39,10 @@ type BinaryIndex interface {
 	// Ntotal returns the total number of vectors currently stored in the index.
 	Ntotal() int64
 
+	// IsTrained returns true if the index has been trained or does not require
+	// training.
+	IsTrained() bool
+
 	// set the direct map type for IVF indexes.
 	// 0 for No Map
 	// 1 for Array
@@ -48,12 +58,49 @@ type BinaryIndex interface {
 	// IVFParams returns the nlist and nprobe parameters for IVF indexes
 	IVFParams() (nprobe int, nlist int)
 
+	// InvertedLists returns a view onto the inverted lists of binary IVF indexes.
+	InvertedLists() (*InvertedLists, error)
+
+	// Returns true if the index is a binary HNSW index.
+	IsHNSWIndex() bool
+
+	// Returns the HNSW parameters efSearch and efConstruction for HNSW
+	// indexes.
+	HNSWParams() (efSearch, efConstruction int)
+
+	// set the size of the candidate list explored by searches of HNSW
+	// indexes, trading latency for recall.
+	SetEfSearch(ef int) error
+
+	// set the size of the candidate list explored when adding vectors to
+	// HNSW indexes, trading indexing time for graph quality.
+	SetEfConstruction(ef int) error
+
+	// Returns true if the index is a hash or multi-hash index.
+	IsHashIndex() bool
+
+	// Returns the number of bits per hash, the number of hashes (1 for a
+	// hash index) and the number of bit flips explored by searches for hash
+	// and multi-hash indexes.
+	HashParams() (b, nhash, nflip int)
+
+	// set the number of bit flips explored by searches of hash and
+	// multi-hash indexes, trading latency for recall.
+	SetHashNflip(nflip int) error
+
 	// trains the index on a representative set of vectors
 	Train(xb []uint8) error
 
 	// adds vectors to the index
 	Add(xb []uint8) error
 
+	// like Add, but adds the vectors in batches and stops early, returning an
+	// error wrapping ctx.Err(), once ctx is done
+	AddContext(ctx context.Context, xb []uint8) error
+
+	// like Add, but stores xids instead of sequential IDs
+	AddWithIDs(xb []uint8, xids []int64) error
+
 	// sets the qunatizers from the source index, supposed to be used only for
 	// BIVF indexes and returns error otherwise
 	SetQuantizers(srcIndex BinaryIndex) error
@@ -62,6 +109,11 @@ type BinaryIndex interface {
 	// IVF indexes returns an error
 	MergeFrom(other BinaryIndex, add_id int64) error
 
+	// split a binary IVF index into new ones by ID range or by list, like the
+	// SplitByIDRange and SplitByLists methods of Index
+	SplitByIDRange(boundaries []int64) ([]BinaryIndex, error)
+	SplitByLists(groups [][]int64) ([]BinaryIndex, error)
+
 	// queries the index with the vectors in xb
 	// returns the IDs of the k nearest neighbors for each query vector and
 	// their corresponding distances
@@ -72,6 +124,45 @@ type BinaryIndex interface {
 	// - params is a JSON object that can contain additional search parameters specific to the index type, such as IVF search parameters.
 	SearchWithOptions(xb []uint8, k int64, sel Selector, params json.RawMessage) (distances []int32, labels []int64, err error)
 
+	// like Search and SearchWithOptions, but write the results into the caller
+	// supplied distances and labels, which must hold at least n*k values each
+	// for n query vectors
+	SearchInto(xb []uint8, k int64, distances []int32, labels []int64) error
+	SearchWithOptionsInto(xb []uint8, k int64, sel Selector, params json.RawMessage, distances []int32, labels []int64) error
+
+	// like Search and SearchWithOptions, but search the queries in batches and
+	// stop early, aborting the batch under way in faiss and returning an error
+	// wrapping ctx.Err(), once ctx is done
+	SearchContext(ctx context.Context, xb []uint8, k int64) (distances []int32, labels []int64, err error)
+	SearchWithOptionsContext(ctx context.Context, xb []uint8, k int64, sel Selector, params json.RawMessage) (distances []int32, labels []int64, err error)
+
+	// RangeSearch queries the index with the vectors in xb.
+	// Returns all vectors with a Hamming distance < radius.
+	RangeSearch(xb []uint8, radius int32) (*BinaryRangeSearchResult, error)
+
+	// like RangeSearch, but searches the queries in batches and stops early,
+	// aborting the batch under way in faiss and returning an error wrapping
+	// ctx.Err(), once ctx is done
+	RangeSearchContext(ctx context.Context, xb []uint8, radius int32) (*BinaryRangeSearchResult, error)
+
+	// Reconstruct returns the vector with ID key.
+	Reconstruct(key int64) ([]uint8, error)
+
+	// ReconstructBatch writes the vectors with the given IDs into recons,
+	// which must hold at least len(keys)*D()/8 bytes, and returns it.
+	ReconstructBatch(keys []int64, recons []uint8) ([]uint8, error)
+
+	// DistCompute computes the Hamming distances between the query vector and
+	// the vectors specified by ids.
+	DistCompute(xb []uint8, ids []int64) ([]int32, error)
+
+	// Reset removes all vectors from the index.
+	Reset() error
+
+	// RemoveIDs removes the vectors specified by sel from the index.
+	// Returns the number of elements removed and error.
+	RemoveIDs(sel *IDSelector) (int, error)
+
 	// returns a slice where each index corresponds to a cluster in an IVF
 	// index, and the value at each index is the count of vectors in that
 	// cluster, considering only the vectors specified in the include selector.
@@ -99,37 +190,96 @@ type BinaryIndex interface {
 	// if the underlying faiss index is memory-mapped and not fully loaded into memory.
 	Size() uint64
 
-	// frees the memory associated with the index
+	// frees the memory associated with the index. It is safe to call Close
+	// more than once; any other method called after Close returns
+	// ErrIndexClosed (or a zero value, for methods that cannot return an error).
 	Close()
 
 	// bPtr returns a pointer to the underlying C index struct.
 	bPtr() *C.FaissIndexBinary
 
+	// impl returns the underlying faissBinaryIndex.
+	impl() *faissBinaryIndex
+
 	// CodeSize returns the size of the produced codes in bytes.
 	CodeSize() (uint64, error)
 }
 
 type faissBinaryIndex struct {
 	bIdx *C.FaissIndexBinary
+
+	// cleanup frees bIdx if the index is garbage collected without Close.
+	cleanup runtime.Cleanup
+
+	// parent is set when bIdx is owned by another index (such as the
+	// sub-index of an IDMap), in which case bIdx is never freed here.
+	parent *faissBinaryIndex
 }
 
+// newFaissBinaryIndex wraps a binary index allocated on the C heap, taking
+// ownership of it.
+func newFaissBinaryIndex(ptr *C.FaissIndexBinary) *faissBinaryIndex {
+	b := &faissBinaryIndex{bIdx: ptr}
+	b.cleanup = addLeakCleanup(b, "faiss binary index", func() {
+		C.faiss_IndexBinary_free(ptr)
+	})
+	return b
+}
+
+// closed returns true if the index, or the index owning it, has been closed.
+func (b *faissBinaryIndex) closed() bool {
+	return b.bIdx == nil || (b.parent != nil && b.parent.closed())
+}
+
+// bPtr returns nil once the index has been closed.
 func (b *faissBinaryIndex) bPtr() *C.FaissIndexBinary {
+	if b.closed() {
+		return nil
+	}
 	return b.bIdx
 }
 
+func (b *faissBinaryIndex) impl() *faissBinaryIndex {
+	return b
+}
+
 func (b *faissBinaryIndex) D() int {
+	if b.closed() {
+		return 0
+	}
+	defer runtime.KeepAlive(b)
 	return int(C.faiss_IndexBinary_d(b.bIdx))
 }
 
 func (b *faissBinaryIndex) MetricType() int {
+	if b.closed() {
+		return 0
+	}
+	defer runtime.KeepAlive(b)
 	return int(C.faiss_IndexBinary_metric_type(b.bIdx))
 }
 
 func (b *faissBinaryIndex) Ntotal() int64 {
+	if b.closed() {
+		return 0
+	}
+	defer runtime.KeepAlive(b)
 	return int64(C.faiss_IndexBinary_ntotal(b.bIdx))
 }
 
+func (b *faissBinaryIndex) IsTrained() bool {
+	if b.closed() {
+		return false
+	}
+	defer runtime.KeepAlive(b)
+	return C.faiss_IndexBinary_is_trained(b.bIdx) != 0
+}
+
 func (b *faissBinaryIndex) SetDirectMap(mapType int) (err error) {
+	if b.closed() {
+		return ErrIndexClosed
+	}
+	defer runtime.KeepAlive(b)
 	// Applicable only to IVF indexes
 	ivfPtrBinary := C.faiss_IndexBinaryIVF_cast(b.bIdx)
 	if ivfPtrBinary == nil {
@@ -145,6 +295,10 @@ func (b *faissBinaryIndex) SetDirectMap(mapType int) (err error) {
 }
 
 func (b *faissBinaryIndex) SetNProbe(nprobe int32) {
+	if b.closed() {
+		return
+	}
+	defer runtime.KeepAlive(b)
 	// Applicable only to IVF indexes
 	ivfPtrBinary := C.faiss_IndexBinaryIVF_cast(b.bIdx)
 	if ivfPtrBinary == nil {
@@ -154,11 +308,19 @@ func (b *faissBinaryIndex) SetNProbe(nprobe int32) {
 }
 
 func (b *faissBinaryIndex) IsIVFIndex() bool {
+	if b.closed() {
+		return false
+	}
+	defer runtime.KeepAlive(b)
 	ivfPtrBinary := C.faiss_IndexBinaryIVF_cast(b.bIdx)
 	return ivfPtrBinary != nil
 }
 
 func (b *faissBinaryIndex) IVFParams() (nprobe int, nlist int) {
+	if b.closed() {
+		return 0, 0
+	}
+	defer runtime.KeepAlive(b)
 	// Applicable only to IVF indexes
 	ivfPtrBinary := C.faiss_IndexBinaryIVF_cast(b.bIdx)
 	if ivfPtrBinary == nil {
@@ -170,7 +332,14 @@ func (b *faissBinaryIndex) IVFParams() (nprobe int, nlist int) {
 }
 
 func (b *faissBinaryIndex) Train(x []uint8) error {
-	n := (len(x) * 8) / b.D()
+	if b.closed() {
+		return ErrIndexClosed
+	}
+	defer runtime.KeepAlive(b)
+	n, err := validateBinaryVectors(x, b.D())
+	if err != nil {
+		return err
+	}
 	if c := C.faiss_IndexBinary_train(b.bIdx, C.idx_t(n),
 		(*C.uint8_t)(&x[0])); c != 0 {
 		return newFaissError(ErrTrainFailed, getLastError(), int(c))
@@ -179,7 +348,14 @@ func (b *faissBinaryIndex) Train(x []uint8) error {
 }
 
 func (b *faissBinaryIndex) Add(x []uint8) error {
-	n := (len(x) * 8) / b.D()
+	if b.closed() {
+		return ErrIndexClosed
+	}
+	defer runtime.KeepAlive(b)
+	n, err := validateBinaryVectors(x, b.D())
+	if err != nil {
+		return err
+	}
 	if c := C.faiss_IndexBinary_add(b.bIdx, C.idx_t(n),
 		(*C.uint8_t)(&x[0])); c != 0 {
 		return newFaissError(ErrAddFailed, getLastError(), int(c))
@@ -187,11 +363,59 @@ func (b *faissBinaryIndex) Add(x []uint8) error {
 	return nil
 }
 
+func (b *faissBinaryIndex) AddWithIDs(x []uint8, xids []int64) error {
+	if b.closed() {
+		return ErrIndexClosed
+	}
+	defer runtime.KeepAlive(b)
+	n, err := validateBinaryVectors(x, b.D())
+	if err != nil {
+		return err
+	}
+	if err := validateIDs(xids, n); err != nil {
+		return err
+	}
+	if c := C.faiss_IndexBinary_add_with_ids(
+		b.bIdx,
+		C.idx_t(n),
+		(*C.uint8_t)(&x[0]),
+		(*C.idx_t)(&xids[0]),
+	); c != 0 {
+		return newFaissError(ErrAddFailed, getLastError(), int(c))
+	}
+	return nil
+}
+
 func (b *faissBinaryIndex) Search(xb []uint8, k int64) (
 	[]int32, []int64, error) {
-	nq := (len(xb) * 8) / b.D()
+	if b.closed() {
+		return nil, nil, ErrIndexClosed
+	}
+	nq, err := validateBinaryVectors(xb, b.D())
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := validateK(k); err != nil {
+		return nil, nil, err
+	}
 	distances := make([]int32, int64(nq)*k)
 	labels := make([]int64, int64(nq)*k)
+	if err := b.SearchInto(xb, k, distances, labels); err != nil {
+		return nil, nil, err
+	}
+	return distances, labels, nil
+}
+
+func (b *faissBinaryIndex) SearchInto(xb []uint8, k int64, distances []int32,
+	labels []int64) error {
+	if b.closed() {
+		return ErrIndexClosed
+	}
+	defer runtime.KeepAlive(b)
+	nq, err := validateBinarySearchInto(xb, b.D(), k, len(distances), len(labels))
+	if err != nil {
+		return err
+	}
 
 	if c := C.faiss_IndexBinary_search(
 		b.bIdx,
@@ -201,32 +425,56 @@ func (b *faissBinaryIndex) Search(xb []uint8, k int64) (
 		(*C.int32_t)(&distances[0]),
 		(*C.idx_t)(&labels[0]),
 	); c != 0 {
-		return nil, nil, newFaissError(ErrSearchFailed, getLastError(), int(c))
+		return newFaissError(ErrSearchFailed, getLastError(), int(c))
 	}
-	return distances, labels, nil
+	return nil
 }
 
 func (b *faissBinaryIndex) SearchWithOptions(xb []uint8, k int64, sel Selector, params json.RawMessage) ([]int32, []int64, error) {
+	if b.closed() {
+		return nil, nil, ErrIndexClosed
+	}
+	nq, err := validateBinaryVectors(xb, b.D())
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := validateK(k); err != nil {
+		return nil, nil, err
+	}
+	distances := make([]int32, int64(nq)*k)
+	labels := make([]int64, int64(nq)*k)
+	if err := b.SearchWithOptionsInto(xb, k, sel, params, distances, labels); err != nil {
+		return nil, nil, err
+	}
+	return distances, labels, nil
+}
+
+func (b *faissBinaryIndex) SearchWithOptionsInto(xb []uint8, k int64, sel Selector,
+	params json.RawMessage, distances []int32, labels []int64) error {
+	if b.closed() {
+		return ErrIndexClosed
+	}
+	defer runtime.KeepAlive(b)
 	if sel == nil && params == nil {
-		return b.Search(xb, k)
+		return b.SearchInto(xb, k, distances, labels)
 	}
-	return b.searchWithOptions(xb, k, sel, params)
+	return b.searchWithOptionsInto(xb, k, sel, params, distances, labels)
 }
 
-func (b *faissBinaryIndex) searchWithOptions(xb []uint8, k int64, selector Selector,
-	params json.RawMessage) ([]int32, []int64, error) {
+func (b *faissBinaryIndex) searchWithOptionsInto(xb []uint8, k int64, selector Selector,
+	params json.RawMessage, distances []int32, labels []int64) error {
+	nq, err := validateBinarySearchInto(xb, b.D(), k, len(distances), len(labels))
+	if err != nil {
+		return err
+	}
 	// Build a binary search params object to contain either the selector, the additional params, or both.
 	searchParams, err := NewBinarySearchParams(b, params, selector, nil)
 	if err != nil {
-		return nil, nil, err
+		return err
 	}
 	defer searchParams.Delete()
 
-	nq := (len(xb) * 8) / b.D()
-	distances := make([]int32, int64(nq)*k)
-	labels := make([]int64, int64(nq)*k)
-
-	if c := C.faiss_IndexBinary_search_with_params(
+	c := C.faiss_IndexBinary_search_with_params(
 		b.bIdx,
 		C.idx_t(nq),
 		(*C.uint8_t)(&xb[0]),
@@ -234,13 +482,21 @@ func (b *faissBinaryIndex) searchWithOptions(xb []uint8, k int64, selector Selec
 		searchParams.sp,
 		(*C.int32_t)(&distances[0]),
 		(*C.idx_t)(&labels[0]),
-	); c != 0 {
-		return nil, nil, newFaissError(ErrSearchFailed, getLastError(), int(c))
+	)
+	if err := selectorError(selector); err != nil {
+		return err
 	}
-	return distances, labels, nil
+	if c != 0 {
+		return newFaissError(ErrSearchFailed, getLastError(), int(c))
+	}
+	return nil
 }
 
 func (b *faissBinaryIndex) ObtainClusterVectorCountsFromIVFIndex(includedVectors Selector, nlist int) ([]int64, error) {
+	if b.closed() {
+		return nil, ErrIndexClosed
+	}
+	defer runtime.KeepAlive(b)
 	// Applicable only to IVF indexes
 	ivfPtrBinary := C.faiss_IndexBinaryIVF_cast(b.bIdx)
 	if ivfPtrBinary == nil {
@@ -259,23 +515,38 @@ func (b *faissBinaryIndex) ObtainClusterVectorCountsFromIVFIndex(includedVectors
 	// Calling the C function to populate listCount
 	// with the count of vectors per cluster, considering only
 	// the vectors specified in the include selector.
-	if c := C.faiss_IndexBinaryIVF_list_vector_count(
+	c := C.faiss_IndexBinaryIVF_list_vector_count(
 		ivfPtrBinary,
 		(*C.idx_t)(unsafe.Pointer(&listCount[0])),
 		C.size_t(nlist),
 		params.sp,
-	); c != 0 {
+	)
+	if err := selectorError(includedVectors); err != nil {
+		return nil, err
+	}
+	if c != 0 {
 		return nil, newFaissError(ErrInspectIndexFailed, getLastError(), int(c))
 	}
 	return listCount, nil
 }
 
 func (b *faissBinaryIndex) ObtainClustersWithDistancesFromIVFIndex(xb []uint8, includedCentroids Selector, numCentroids int64) ([]int64, []int32, error) {
+	if b.closed() {
+		return nil, nil, ErrIndexClosed
+	}
+	defer runtime.KeepAlive(b)
 	// Applicable only to IVF indexes
 	ivfPtrBinary := C.faiss_IndexBinaryIVF_cast(b.bIdx)
 	if ivfPtrBinary == nil {
 		return nil, nil, ErrNotBIVFIndex
 	}
+	n, err := validateBinaryVectors(xb, b.D())
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := validateK(numCentroids); err != nil {
+		return nil, nil, err
+	}
 	params, err := NewStandardSearchParams(includedCentroids)
 	if err != nil {
 		return nil, nil, err
@@ -283,12 +554,10 @@ func (b *faissBinaryIndex) ObtainClustersWithDistancesFromIVFIndex(xb []uint8, i
 	defer params.Delete()
 
 	// Populate these with the centroids and their distances.
-	centroids := make([]int64, numCentroids)
-	centroidDistances := make([]int32, numCentroids)
+	centroids := make([]int64, int64(n)*numCentroids)
+	centroidDistances := make([]int32, int64(n)*numCentroids)
 
-	n := (len(xb) * 8) / b.D()
-
-	if c := C.faiss_IndexBinaryIVF_search_closest_eligible_centroids(
+	c := C.faiss_IndexBinaryIVF_search_closest_eligible_centroids(
 		ivfPtrBinary,
 		(C.idx_t)(n),
 		(*C.uint8_t)(&xb[0]),
@@ -296,7 +565,11 @@ func (b *faissBinaryIndex) ObtainClustersWithDistancesFromIVFIndex(xb []uint8, i
 		(*C.int32_t)(&centroidDistances[0]),
 		(*C.idx_t)(&centroids[0]),
 		params.sp,
-	); c != 0 {
+	)
+	if err := selectorError(includedCentroids); err != nil {
+		return nil, nil, err
+	}
+	if c != 0 {
 		return nil, nil, newFaissError(ErrSearchFailed, getLastError(), int(c))
 	}
 
@@ -305,6 +578,10 @@ func (b *faissBinaryIndex) ObtainClustersWithDistancesFromIVFIndex(xb []uint8, i
 
 func (b *faissBinaryIndex) ObtainKCentroidCardinalitiesFromIVFIndex(limit int, descending bool) (
 	[]uint64, [][]uint8, error) {
+	if b.closed() {
+		return nil, nil, ErrIndexClosed
+	}
+	defer runtime.KeepAlive(b)
 	if limit <= 0 {
 		return nil, nil, nil
 	}
@@ -323,8 +600,8 @@ func (b *faissBinaryIndex) ObtainKCentroidCardinalitiesFromIVFIndex(limit int, d
 	centroidCardinalities := make([]C.size_t, nlist)
 
 	// Allocate a flat buffer for all centroids, then slice it per centroid
-	d := b.D()
-	flatCentroids := make([]uint8, nlist*d/8)
+	codeSize := b.D() / 8
+	flatCentroids := make([]uint8, nlist*codeSize)
 
 	// Call the C function to fill centroid vectors and cardinalities
 	c := C.faiss_IndexBinaryIVF_get_centroids_and_cardinality(
@@ -347,7 +624,7 @@ func (b *faissBinaryIndex) ObtainKCentroidCardinalitiesFromIVFIndex(limit int, d
 
 	for i, idx := range topIndices {
 		rvCardinalities[i] = uint64(centroidCardinalities[idx])
-		rvCentroids[i] = flatCentroids[idx*d : (idx+1)*d]
+		rvCentroids[i] = flatCentroids[idx*codeSize : (idx+1)*codeSize]
 	}
 
 	return rvCardinalities, rvCentroids, nil
@@ -356,6 +633,10 @@ func (b *faissBinaryIndex) ObtainKCentroidCardinalitiesFromIVFIndex(limit int, d
 
 func (b *faissBinaryIndex) SearchClustersFromIVFIndex(eligibleCentroidIDs []int64, centroidDis []int32, centroidsToProbe int,
 	xb []uint8, k int64, include Selector, params json.RawMessage) ([]int32, []int64, error) {
+	if b.closed() {
+		return nil, nil, ErrIndexClosed
+	}
+	defer runtime.KeepAlive(b)
 	// Applicable only to IVF indexes
 	ivfPtrBinary := C.faiss_IndexBinaryIVF_cast(b.bIdx)
 	if ivfPtrBinary == nil {
@@ -366,6 +647,19 @@ func (b *faissBinaryIndex) SearchClustersFromIVFIndex(eligibleCentroidIDs []int6
 	if include == nil {
 		return nil, nil, ErrSelectorNil
 	}
+	n, err := validateBinaryVectors(xb, b.D())
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := validateK(k); err != nil {
+		return nil, nil, err
+	}
+	if len(eligibleCentroidIDs) == 0 {
+		return nil, nil, ErrEmptyInput
+	}
+	if err := validateBuffer(len(centroidDis), len(eligibleCentroidIDs)); err != nil {
+		return nil, nil, err
+	}
 	// create a temporary search params object to set nprobe, this will override
 	// the nprobe and the nlist set at index time, this will allow the search to
 	// probe only the clusters specified in eligibleCentroidIDs
@@ -383,8 +677,6 @@ func (b *faissBinaryIndex) SearchClustersFromIVFIndex(eligibleCentroidIDs []int6
 	}
 	defer searchParams.Delete()
 
-	n := (len(xb) * 8) / b.D()
-
 	distances := make([]int32, int64(n)*k)
 	labels := make([]int64, int64(n)*k)
 	// Adjust the slices to match the effective nprobe set in searchParams, as the input
@@ -396,7 +688,7 @@ func (b *faissBinaryIndex) SearchClustersFromIVFIndex(eligibleCentroidIDs []int6
 	eligibleCentroidIDs = eligibleCentroidIDs[:effectiveNprobe]
 	centroidDis = centroidDis[:effectiveNprobe]
 
-	if c := C.faiss_IndexBinaryIVF_search_preassigned_with_params(
+	c := C.faiss_IndexBinaryIVF_search_preassigned_with_params(
 		ivfPtrBinary,
 		(C.idx_t)(n),
 		(*C.uint8_t)(&xb[0]),
@@ -407,7 +699,11 @@ func (b *faissBinaryIndex) SearchClustersFromIVFIndex(eligibleCentroidIDs []int6
 		(*C.idx_t)(&labels[0]),
 		(C.int)(0),
 		searchParams.sp,
-	); c != 0 {
+	)
+	if err := selectorError(include); err != nil {
+		return nil, nil, err
+	}
+	if c != 0 {
 		return nil, nil, newFaissError(ErrSearchFailed, getLastError(), int(c))
 	}
 
@@ -416,6 +712,10 @@ func (b *faissBinaryIndex) SearchClustersFromIVFIndex(eligibleCentroidIDs []int6
 
 func (b *faissBinaryIndex) Size() uint64 {
 	rv := reflectStaticSizeFaissBinaryIndex
+	if b.closed() {
+		return rv
+	}
+	defer runtime.KeepAlive(b)
 	var size C.size_t
 	if code := C.faiss_IndexBinary_size(b.bIdx, &size); code == 0 {
 		rv += uint64(size)
@@ -424,6 +724,10 @@ func (b *faissBinaryIndex) Size() uint64 {
 }
 
 func (b *faissBinaryIndex) CodeSize() (uint64, error) {
+	if b.closed() {
+		return 0, ErrIndexClosed
+	}
+	defer runtime.KeepAlive(b)
 	var size C.size_t
 	if c := C.faiss_IndexBinary_sa_code_size(b.bIdx, &size); c != 0 {
 		return 0, newFaissError(ErrInspectIndexFailed, getLastError(), int(c))
@@ -431,8 +735,207 @@ func (b *faissBinaryIndex) CodeSize() (uint64, error) {
 	return uint64(size), nil
 }
 
+func (b *faissBinaryIndex) RangeSearch(xb []uint8, radius int32) (
+	*BinaryRangeSearchResult, error) {
+	if b.closed() {
+		return nil, ErrIndexClosed
+	}
+	defer runtime.KeepAlive(b)
+	n, err := validateBinaryVectors(xb, b.D())
+	if err != nil {
+		return nil, err
+	}
+	var rsr *C.FaissRangeSearchResult
+	if c := C.faiss_RangeSearchResult_new(&rsr, C.idx_t(n)); c != 0 {
+		return nil, newFaissError(ErrSearchFailed, getLastError(), int(c))
+	}
+	if c := C.faiss_IndexBinary_range_search(
+		b.bIdx,
+		C.idx_t(n),
+		(*C.uint8_t)(&xb[0]),
+		C.int(radius),
+		rsr,
+	); c != 0 {
+		C.faiss_RangeSearchResult_free(rsr)
+		return nil, newFaissError(ErrSearchFailed, getLastError(), int(c))
+	}
+	// Hamming distances order like L2 ones.
+	return &BinaryRangeSearchResult{newRangeSearchResult(rsr, MetricL2)}, nil
+}
+
+func (b *faissBinaryIndex) Reconstruct(key int64) ([]uint8, error) {
+	if b.closed() {
+		return nil, ErrIndexClosed
+	}
+	defer runtime.KeepAlive(b)
+	rv := make([]uint8, b.D()/8)
+	if c := C.faiss_IndexBinary_reconstruct(
+		b.bIdx,
+		C.idx_t(key),
+		(*C.uint8_t)(&rv[0]),
+	); c != 0 {
+		return nil, newFaissError(ErrReconstructFailed, getLastError(), int(c))
+	}
+	return rv, nil
+}
+
+// The C API has no batched reconstruction for binary indexes, so the vectors
+// are reconstructed one at a time.
+func (b *faissBinaryIndex) ReconstructBatch(keys []int64, recons []uint8) ([]uint8, error) {
+	if b.closed() {
+		return nil, ErrIndexClosed
+	}
+	defer runtime.KeepAlive(b)
+	if len(keys) == 0 {
+		return nil, ErrEmptyInput
+	}
+	codeSize := b.D() / 8
+	if err := validateBuffer(len(recons), len(keys)*codeSize); err != nil {
+		return nil, err
+	}
+	for i, key := range keys {
+		if c := C.faiss_IndexBinary_reconstruct(
+			b.bIdx,
+			C.idx_t(key),
+			(*C.uint8_t)(&recons[i*codeSize]),
+		); c != 0 {
+			return recons, newFaissError(ErrReconstructFailed, getLastError(), int(c))
+		}
+	}
+	return recons, nil
+}
+
+// DistCompute reconstructs the vectors, so it fails with ErrReconstructFailed
+// on indexes which do not support Reconstruct, such as IVF indexes without a
+// direct map.
+func (b *faissBinaryIndex) DistCompute(xb []uint8, ids []int64) ([]int32, error) {
+	if b.closed() {
+		return nil, ErrIndexClosed
+	}
+	// exactly one query vector is compared against all of ids
+	if n, err := validateBinaryVectors(xb, b.D()); err != nil {
+		return nil, err
+	} else if n != 1 {
+		return nil, fmt.Errorf("%w: expected a single query vector, got %d",
+			ErrDimensionMismatch, n)
+	}
+	if len(ids) == 0 {
+		return nil, ErrEmptyInput
+	}
+	codeSize := len(xb)
+	recons, err := b.ReconstructBatch(ids, make([]uint8, len(ids)*codeSize))
+	if err != nil {
+		return nil, err
+	}
+	distances := make([]int32, len(ids))
+	for i := range ids {
+		distances[i] = hammingDistance(xb, recons[i*codeSize:(i+1)*codeSize])
+	}
+	return distances, nil
+}
+
+// hammingDistance returns the number of bits which differ between a and b,
+// which have the same length.
+func hammingDistance(a, b []uint8) int32 {
+	var rv int
+	for len(a) >= 8 {
+		rv += bits.OnesCount64(binary.LittleEndian.Uint64(a) ^
+			binary.LittleEndian.Uint64(b))
+		a, b = a[8:], b[8:]
+	}
+	for i := range a {
+		rv += bits.OnesCount8(a[i] ^ b[i])
+	}
+	return int32(rv)
+}
+
+func (b *faissBinaryIndex) Reset() error {
+	if b.closed() {
+		return ErrIndexClosed
+	}
+	defer runtime.KeepAlive(b)
+	if c := C.faiss_IndexBinary_reset(b.bIdx); c != 0 {
+		return newFaissError(ErrResetIndexFailed, getLastError(), int(c))
+	}
+	return nil
+}
+
+func (b *faissBinaryIndex) RemoveIDs(sel *IDSelector) (int, error) {
+	if b.closed() {
+		return 0, ErrIndexClosed
+	}
+	if sel == nil {
+		return 0, ErrSelectorNil
+	}
+	if sel.sel == nil {
+		return 0, ErrSelectorClosed
+	}
+	defer runtime.KeepAlive(b)
+	defer runtime.KeepAlive(sel)
+	var nRemoved C.size_t
+	c := C.faiss_IndexBinary_remove_ids(b.bIdx, sel.sel, &nRemoved)
+	if err := selectorError(sel); err != nil {
+		return int(nRemoved), err
+	}
+	if c != 0 {
+		return 0, newFaissError(ErrRemoveIDsFailed, getLastError(), int(c))
+	}
+	return int(nRemoved), nil
+}
+
 func (idx *faissBinaryIndex) Close() {
-	C.faiss_IndexBinary_free(idx.bIdx)
+	if idx.bIdx == nil {
+		return
+	}
+	if idx.parent == nil {
+		idx.cleanup.Stop()
+		C.faiss_IndexBinary_free(idx.bIdx)
+	}
+	idx.bIdx = nil
+}
+
+// BinaryRangeSearchResult is the result of a range search of a binary index.
+// faiss stores the Hamming distances as floats, which Labels converts back.
+type BinaryRangeSearchResult struct {
+	rsr *RangeSearchResult
+}
+
+// Nq returns the number of queries.
+func (r *BinaryRangeSearchResult) Nq() int {
+	return r.rsr.Nq()
+}
+
+// Lims returns a slice containing start and end indices for queries in the
+// distances and labels slices returned by Labels.
+// The returned slice points into C memory owned by r and is only valid until
+// Delete.
+func (r *BinaryRangeSearchResult) Lims() []int {
+	return r.rsr.Lims()
+}
+
+// Labels returns the unsorted IDs and respective Hamming distances for each
+// query. The result for query i is labels[lims[i]:lims[i+1]].
+// The returned labels point into C memory owned by r and are only valid until
+// Delete, while the distances are a copy.
+func (r *BinaryRangeSearchResult) Labels() (labels []int64, distances []int32) {
+	labels, fdistances := r.rsr.Labels()
+	if labels == nil {
+		return nil, nil
+	}
+	distances = make([]int32, len(fdistances))
+	for i, d := range fdistances {
+		distances[i] = int32(d)
+	}
+	return labels, distances
+}
+
+// Delete frees the memory associated with r. It is safe to call Delete more
+// than once.
+func (r *BinaryRangeSearchResult) Delete() {
+	if r == nil {
+		return
+	}
+	r.rsr.Delete()
 }
 
 type BinaryIndexImpl struct {
@@ -445,14 +948,19 @@ func BinaryIndexFactory(dims int, description string) (*BinaryIndexImpl, error)
 		cDescription = C.CString(description)
 		defer C.free(unsafe.Pointer(cDescription))
 	}
-	var idx faissBinaryIndex
-	if c := C.faiss_index_binary_factory(&idx.bIdx, C.int(dims), cDescription); c != 0 {
+	var idx *C.FaissIndexBinary
+	if c := C.faiss_index_binary_factory(&idx, C.int(dims), cDescription); c != 0 {
 		return nil, newFaissError(ErrCreateIndexFailed, getLastError(), int(c))
 	}
-	return &BinaryIndexImpl{&idx}, nil
+	return &BinaryIndexImpl{newFaissBinaryIndex(idx)}, nil
 }
 
 func (idx *faissBinaryIndex) SetQuantizers(srcIndex BinaryIndex) error {
+	if idx.closed() || srcIndex.bPtr() == nil {
+		return ErrIndexClosed
+	}
+	defer runtime.KeepAlive(idx)
+	defer runtime.KeepAlive(srcIndex)
 	if !(idx.IsIVFIndex() && srcIndex.IsIVFIndex()) {
 		return ErrSetQuantizerNotSupported
 	}
@@ -464,6 +972,11 @@ func (idx *faissBinaryIndex) SetQuantizers(srcIndex BinaryIndex) error {
 }
 
 func (idx *faissBinaryIndex) MergeFrom(other BinaryIndex, add_id int64) (err error) {
+	if idx.closed() || other.bPtr() == nil {
+		return ErrIndexClosed
+	}
+	defer runtime.KeepAlive(idx)
+	defer runtime.KeepAlive(other)
 	if !(idx.IsIVFIndex() && other.IsIVFIndex()) {
 		return ErrMergeFromNotSupported
 	}
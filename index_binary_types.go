@@ -0,0 +1,293 @@
+package faiss
+
+/*
+#include "gofaiss.h"
+*/
+import "C"
+import (
+	"fmt"
+	"runtime"
+)
+
+// validateBinaryDimension checks that d is a valid number of bits for a
+// binary index, which stores vectors as whole bytes.
+func validateBinaryDimension(d int) error {
+	if d <= 0 || d%8 != 0 {
+		return fmt.Errorf("%w: d must be a positive multiple of 8, got %d",
+			ErrCreateIndexFailed, d)
+	}
+	return nil
+}
+
+// BinaryIndexFlat is a binary index that stores the full vectors and performs
+// exhaustive search.
+type BinaryIndexFlat struct {
+	BinaryIndex
+}
+
+// NewBinaryIndexFlat creates a new flat index of d-bit vectors.
+func NewBinaryIndexFlat(d int) (*BinaryIndexFlat, error) {
+	if err := validateBinaryDimension(d); err != nil {
+		return nil, err
+	}
+	var idx *C.FaissIndexBinary
+	if c := C.gofaiss_IndexBinaryFlat_new(&idx, C.int(d)); c != 0 {
+		return nil, newFaissError(ErrCreateIndexFailed, getLastError(), int(c))
+	}
+	return &BinaryIndexFlat{newFaissBinaryIndex(idx)}, nil
+}
+
+// BinaryIndexIVF is a binary index partitioning the vectors into nlist
+// inverted lists, of which nprobe are scanned per query.
+type BinaryIndexIVF struct {
+	BinaryIndex
+}
+
+// NewBinaryIndexIVF creates a new IVF index of d-bit vectors with nlist
+// inverted lists, assigned by quantizer, typically a BinaryIndexFlat of the
+// same dimension. Training it trains quantizer on the training vectors.
+//
+// The returned index takes ownership of quantizer: it is freed along with it,
+// and closing it beforehand does nothing but make it unusable.
+func NewBinaryIndexIVF(quantizer BinaryIndex, d, nlist int) (
+	*BinaryIndexIVF, error) {
+	if quantizer == nil {
+		return nil, ErrIndexNil
+	}
+	if err := validateBinaryDimension(d); err != nil {
+		return nil, err
+	}
+	if nlist <= 0 {
+		return nil, fmt.Errorf("%w: nlist must be positive, got %d",
+			ErrCreateIndexFailed, nlist)
+	}
+	q := quantizer.impl()
+	if q.closed() {
+		return nil, ErrIndexClosed
+	}
+	if q.parent != nil {
+		return nil, fmt.Errorf("%w: quantizer", ErrAlreadyOwned)
+	}
+	if q.D() != d {
+		return nil, fmt.Errorf("%w: quantizer has d=%d, index has d=%d",
+			ErrDimensionMismatch, q.D(), d)
+	}
+	var idx *C.FaissIndexBinary
+	if c := C.gofaiss_IndexBinaryIVF_new(&idx, q.bIdx, C.int(d),
+		C.size_t(nlist)); c != 0 {
+		return nil, newFaissError(ErrCreateIndexFailed, getLastError(), int(c))
+	}
+	rv := newFaissBinaryIndex(idx)
+	q.cleanup.Stop()
+	q.parent = rv
+	return &BinaryIndexIVF{rv}, nil
+}
+
+// BinaryIndexHNSW is a binary index built on a hierarchical navigable small
+// world graph over the full vectors.
+type BinaryIndexHNSW struct {
+	BinaryIndex
+}
+
+// NewBinaryIndexHNSW creates a new HNSW index of d-bit vectors, where M is
+// the number of neighbors of each node on the levels above the bottom one,
+// which has 2*M.
+func NewBinaryIndexHNSW(d, M int) (*BinaryIndexHNSW, error) {
+	if err := validateBinaryDimension(d); err != nil {
+		return nil, err
+	}
+	if M <= 0 {
+		return nil, fmt.Errorf("%w: M must be positive, got %d",
+			ErrCreateIndexFailed, M)
+	}
+	var idx *C.FaissIndexBinary
+	if c := C.gofaiss_IndexBinaryHNSW_new(&idx, C.int(d), C.int(M)); c != 0 {
+		return nil, newFaissError(ErrCreateIndexFailed, getLastError(), int(c))
+	}
+	return &BinaryIndexHNSW{newFaissBinaryIndex(idx)}, nil
+}
+
+// faiss hashes the vectors into 64-bit keys.
+const maxBinaryHashBits = 64
+
+// BinaryIndexHash is a binary index bucketing the vectors by the value of
+// their first b bits, where a search scans the buckets of the keys within
+// nflip bit flips of the query's, see SetHashNflip.
+type BinaryIndexHash struct {
+	BinaryIndex
+}
+
+// NewBinaryIndexHash creates a new hash index of d-bit vectors hashed on
+// their first b bits.
+func NewBinaryIndexHash(d, b int) (*BinaryIndexHash, error) {
+	if err := validateBinaryDimension(d); err != nil {
+		return nil, err
+	}
+	if b <= 0 || b > min(d, maxBinaryHashBits) {
+		return nil, fmt.Errorf("%w: b must be in [1, %d], got %d",
+			ErrCreateIndexFailed, min(d, maxBinaryHashBits), b)
+	}
+	var idx *C.FaissIndexBinary
+	if c := C.gofaiss_IndexBinaryHash_new(&idx, C.int(d), C.int(b)); c != 0 {
+		return nil, newFaissError(ErrCreateIndexFailed, getLastError(), int(c))
+	}
+	return &BinaryIndexHash{newFaissBinaryIndex(idx)}, nil
+}
+
+// BinaryIndexMultiHash is a binary index like BinaryIndexHash, with nhash
+// hash tables built on consecutive b-bit substrings of the vectors, which
+// improves recall.
+type BinaryIndexMultiHash struct {
+	BinaryIndex
+}
+
+// NewBinaryIndexMultiHash creates a new multi-hash index of d-bit vectors
+// with nhash hash tables of b bits each, so that nhash*b must not exceed d.
+func NewBinaryIndexMultiHash(d, nhash, b int) (*BinaryIndexMultiHash, error) {
+	if err := validateBinaryDimension(d); err != nil {
+		return nil, err
+	}
+	if nhash <= 0 {
+		return nil, fmt.Errorf("%w: nhash must be positive, got %d",
+			ErrCreateIndexFailed, nhash)
+	}
+	if b <= 0 || b > maxBinaryHashBits || nhash*b > d {
+		return nil, fmt.Errorf("%w: b must be in [1, %d] with nhash*b <= d, got b=%d, nhash=%d, d=%d",
+			ErrCreateIndexFailed, maxBinaryHashBits, b, nhash, d)
+	}
+	var idx *C.FaissIndexBinary
+	if c := C.gofaiss_IndexBinaryMultiHash_new(&idx, C.int(d), C.int(nhash),
+		C.int(b)); c != 0 {
+		return nil, newFaissError(ErrCreateIndexFailed, getLastError(), int(c))
+	}
+	return &BinaryIndexMultiHash{newFaissBinaryIndex(idx)}, nil
+}
+
+// BinaryIndexIDMap is a binary index storing arbitrary IDs for the vectors of
+// the index it wraps, see AddWithIDs.
+type BinaryIndexIDMap struct {
+	BinaryIndex
+}
+
+// NewBinaryIndexIDMap creates an index mapping the sequential IDs of sub to
+// the IDs given to AddWithIDs. sub must be empty.
+//
+// The returned index takes ownership of sub: it is freed along with it, and
+// closing it beforehand does nothing but make it unusable.
+func NewBinaryIndexIDMap(sub BinaryIndex) (*BinaryIndexIDMap, error) {
+	if sub == nil {
+		return nil, ErrIndexNil
+	}
+	s := sub.impl()
+	if s.closed() {
+		return nil, ErrIndexClosed
+	}
+	if s.parent != nil {
+		return nil, fmt.Errorf("%w: index", ErrAlreadyOwned)
+	}
+	if n := s.Ntotal(); n != 0 {
+		return nil, fmt.Errorf("%w: sub-index holds %d vectors",
+			ErrCreateIndexFailed, n)
+	}
+	var idx *C.FaissIndexBinary
+	if c := C.gofaiss_IndexBinaryIDMap_new(&idx, s.bIdx); c != 0 {
+		return nil, newFaissError(ErrCreateIndexFailed, getLastError(), int(c))
+	}
+	rv := newFaissBinaryIndex(idx)
+	s.cleanup.Stop()
+	s.parent = rv
+	return &BinaryIndexIDMap{rv}, nil
+}
+
+// hnswPtr returns the HNSW index of b, or nil if b is not an HNSW index.
+func (b *faissBinaryIndex) hnswPtr() *C.FaissIndexBinary {
+	return C.gofaiss_IndexBinaryHNSW_cast(b.bPtr())
+}
+
+func (b *faissBinaryIndex) IsHNSWIndex() bool {
+	if b.closed() {
+		return false
+	}
+	defer runtime.KeepAlive(b)
+	return b.hnswPtr() != nil
+}
+
+func (b *faissBinaryIndex) HNSWParams() (efSearch, efConstruction int) {
+	if b.closed() {
+		return 0, 0
+	}
+	defer runtime.KeepAlive(b)
+	hnswPtr := b.hnswPtr()
+	if hnswPtr == nil {
+		return 0, 0
+	}
+	return int(C.gofaiss_IndexBinaryHNSW_efSearch(hnswPtr)),
+		int(C.gofaiss_IndexBinaryHNSW_efConstruction(hnswPtr))
+}
+
+func (b *faissBinaryIndex) SetEfSearch(ef int) error {
+	if b.closed() {
+		return ErrIndexClosed
+	}
+	defer runtime.KeepAlive(b)
+	hnswPtr := b.hnswPtr()
+	if hnswPtr == nil {
+		return ErrNotHNSWIndex
+	}
+	if ef <= 0 {
+		return fmt.Errorf("%w: efSearch must be positive, got %d",
+			ErrSetParamsFailed, ef)
+	}
+	C.gofaiss_IndexBinaryHNSW_set_efSearch(hnswPtr, C.int(ef))
+	return nil
+}
+
+func (b *faissBinaryIndex) SetEfConstruction(ef int) error {
+	if b.closed() {
+		return ErrIndexClosed
+	}
+	defer runtime.KeepAlive(b)
+	hnswPtr := b.hnswPtr()
+	if hnswPtr == nil {
+		return ErrNotHNSWIndex
+	}
+	if ef <= 0 {
+		return fmt.Errorf("%w: efConstruction must be positive, got %d",
+			ErrSetParamsFailed, ef)
+	}
+	C.gofaiss_IndexBinaryHNSW_set_efConstruction(hnswPtr, C.int(ef))
+	return nil
+}
+
+func (b *faissBinaryIndex) IsHashIndex() bool {
+	_, nhash, _ := b.HashParams()
+	return nhash > 0
+}
+
+func (b *faissBinaryIndex) HashParams() (bits, nhash, nflip int) {
+	if b.closed() {
+		return 0, 0, 0
+	}
+	defer runtime.KeepAlive(b)
+	var cBits, cNhash, cNflip C.int
+	if C.gofaiss_IndexBinaryHash_params(b.bIdx, &cBits, &cNhash, &cNflip) == 0 {
+		return 0, 0, 0
+	}
+	return int(cBits), int(cNhash), int(cNflip)
+}
+
+func (b *faissBinaryIndex) SetHashNflip(nflip int) error {
+	if b.closed() {
+		return ErrIndexClosed
+	}
+	defer runtime.KeepAlive(b)
+	if !b.IsHashIndex() {
+		return ErrNotHashIndex
+	}
+	if nflip < 0 {
+		return fmt.Errorf("%w: nflip must not be negative, got %d",
+			ErrSetParamsFailed, nflip)
+	}
+	C.gofaiss_IndexBinaryHash_set_nflip(b.bIdx, C.int(nflip))
+	return nil
+}
@@ -5,7 +5,10 @@ package faiss
 #include <faiss/c_api/Index_c.h>
 */
 import "C"
-import "unsafe"
+import (
+	"runtime"
+	"unsafe"
+)
 
 // IndexFlat is an index that stores the full vectors and performs exhaustive
 // search.
@@ -15,15 +18,15 @@ type IndexFlat struct {
 
 // NewIndexFlat creates a new flat index.
 func NewIndexFlat(d int, metric int) (*IndexFlat, error) {
-	var idx faissIndex
+	var idx *C.FaissIndex
 	if c := C.faiss_IndexFlat_new_with(
-		&idx.idx,
+		&idx,
 		C.idx_t(d),
 		C.FaissMetricType(metric),
 	); c != 0 {
 		return nil, newFaissError(ErrCreateIndexFailed, getLastError(), int(c))
 	}
-	return &IndexFlat{&idx}, nil
+	return &IndexFlat{newFaissIndex(idx)}, nil
 }
 
 // NewIndexFlatIP creates a new flat index with the inner product metric type.
@@ -37,10 +40,16 @@ func NewIndexFlatL2(d int) (*IndexFlat, error) {
 }
 
 // Xb returns the index's vectors.
-// The returned slice becomes invalid after any add or remove operation.
+// The returned slice becomes invalid after any add or remove operation, and
+// after the index is closed.
 func (idx *IndexFlat) Xb() []float32 {
+	ptrIdx := idx.cPtr()
+	if ptrIdx == nil {
+		return nil
+	}
+	defer runtime.KeepAlive(idx)
 	var size C.size_t
 	var ptr *C.float
-	C.faiss_IndexFlat_xb(idx.cPtr(), &ptr, &size)
+	C.faiss_IndexFlat_xb(ptrIdx, &ptr, &size)
 	return (*[1 << 30]float32)(unsafe.Pointer(ptr))[:size:size]
 }
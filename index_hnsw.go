@@ -0,0 +1,177 @@
+package faiss
+
+/*
+#include "gofaiss.h"
+*/
+import "C"
+import (
+	"fmt"
+	"runtime"
+)
+
+// IndexHNSW is an index built on a hierarchical navigable small world graph
+// over the full vectors.
+type IndexHNSW struct {
+	Index
+}
+
+// NewIndexHNSWFlat creates a new HNSW index storing the full vectors, where M
+// is the number of neighbors of each node on the levels above the bottom one,
+// which has 2*M.
+func NewIndexHNSWFlat(d, M int, metric int) (*IndexHNSW, error) {
+	if M <= 0 {
+		return nil, fmt.Errorf("%w: M must be positive, got %d",
+			ErrCreateIndexFailed, M)
+	}
+	var idx *C.FaissIndex
+	if c := C.gofaiss_IndexHNSWFlat_new(
+		&idx,
+		C.int(d),
+		C.int(M),
+		C.FaissMetricType(metric),
+	); c != 0 {
+		return nil, newFaissError(ErrCreateIndexFailed, getLastError(), int(c))
+	}
+	return &IndexHNSW{newFaissIndex(idx)}, nil
+}
+
+// hnswPtr returns the HNSW index of idx, or nil if idx is not an HNSW index.
+func (idx *faissIndex) hnswPtr() *C.FaissIndex {
+	return C.gofaiss_IndexHNSW_cast(idx.cPtr())
+}
+
+func (idx *faissIndex) IsHNSWIndex() bool {
+	if idx.closed() {
+		return false
+	}
+	defer runtime.KeepAlive(idx)
+	return idx.hnswPtr() != nil
+}
+
+func (idx *faissIndex) HNSWParams() (efSearch, efConstruction int) {
+	if idx.closed() {
+		return 0, 0
+	}
+	defer runtime.KeepAlive(idx)
+	hnswPtr := idx.hnswPtr()
+	if hnswPtr == nil {
+		return 0, 0
+	}
+	return int(C.gofaiss_IndexHNSW_efSearch(hnswPtr)),
+		int(C.gofaiss_IndexHNSW_efConstruction(hnswPtr))
+}
+
+func (idx *faissIndex) SetEfSearch(ef int) error {
+	if idx.closed() {
+		return ErrIndexClosed
+	}
+	defer runtime.KeepAlive(idx)
+	hnswPtr := idx.hnswPtr()
+	if hnswPtr == nil {
+		return ErrNotHNSWIndex
+	}
+	if ef <= 0 {
+		return fmt.Errorf("%w: efSearch must be positive, got %d",
+			ErrSetParamsFailed, ef)
+	}
+	C.gofaiss_IndexHNSW_set_efSearch(hnswPtr, C.int(ef))
+	return nil
+}
+
+func (idx *faissIndex) SetEfConstruction(ef int) error {
+	if idx.closed() {
+		return ErrIndexClosed
+	}
+	defer runtime.KeepAlive(idx)
+	hnswPtr := idx.hnswPtr()
+	if hnswPtr == nil {
+		return ErrNotHNSWIndex
+	}
+	if ef <= 0 {
+		return fmt.Errorf("%w: efConstruction must be positive, got %d",
+			ErrSetParamsFailed, ef)
+	}
+	C.gofaiss_IndexHNSW_set_efConstruction(hnswPtr, C.int(ef))
+	return nil
+}
+
+// hnswLevels returns the highest level of each node of the graph.
+func hnswLevels(hnswPtr *C.FaissIndex) []C.int {
+	levels := make([]C.int, C.faiss_Index_ntotal(hnswPtr))
+	if len(levels) > 0 {
+		C.gofaiss_IndexHNSW_levels(hnswPtr, &levels[0])
+	}
+	return levels
+}
+
+func (idx *faissIndex) HNSWLevelCounts() ([]int64, error) {
+	if idx.closed() {
+		return nil, ErrIndexClosed
+	}
+	defer runtime.KeepAlive(idx)
+	hnswPtr := idx.hnswPtr()
+	if hnswPtr == nil {
+		return nil, ErrNotHNSWIndex
+	}
+	maxLevel := int(C.gofaiss_IndexHNSW_max_level(hnswPtr))
+	if maxLevel < 0 {
+		return nil, nil
+	}
+	counts := make([]int64, maxLevel+1)
+	for _, level := range hnswLevels(hnswPtr) {
+		// a node is present on all the levels up to its highest one.
+		for l := 0; l <= int(level) && l <= maxLevel; l++ {
+			counts[l]++
+		}
+	}
+	return counts, nil
+}
+
+// hnswNodeLevel returns the highest level of node id.
+func hnswNodeLevel(hnswPtr *C.FaissIndex, id int64) (int, error) {
+	ntotal := int64(C.faiss_Index_ntotal(hnswPtr))
+	if id < 0 || id >= ntotal {
+		return 0, fmt.Errorf("%w: node %d out of range [0, %d)",
+			ErrInspectIndexFailed, id, ntotal)
+	}
+	return int(C.gofaiss_IndexHNSW_level(hnswPtr, C.idx_t(id))), nil
+}
+
+func (idx *faissIndex) HNSWNodeLevel(id int64) (int, error) {
+	if idx.closed() {
+		return 0, ErrIndexClosed
+	}
+	defer runtime.KeepAlive(idx)
+	hnswPtr := idx.hnswPtr()
+	if hnswPtr == nil {
+		return 0, ErrNotHNSWIndex
+	}
+	return hnswNodeLevel(hnswPtr, id)
+}
+
+func (idx *faissIndex) HNSWNeighbors(id int64, level int) ([]int64, error) {
+	if idx.closed() {
+		return nil, ErrIndexClosed
+	}
+	defer runtime.KeepAlive(idx)
+	hnswPtr := idx.hnswPtr()
+	if hnswPtr == nil {
+		return nil, ErrNotHNSWIndex
+	}
+	nodeLevel, err := hnswNodeLevel(hnswPtr, id)
+	if err != nil {
+		return nil, err
+	}
+	if level < 0 || level > nodeLevel {
+		return nil, fmt.Errorf("%w: level %d out of range [0, %d] of node %d",
+			ErrInspectIndexFailed, level, nodeLevel, id)
+	}
+	neighbors := make([]int64, C.gofaiss_IndexHNSW_nb_neighbors(hnswPtr, C.int(level)))
+	if len(neighbors) == 0 {
+		return neighbors, nil
+	}
+	var n C.size_t
+	C.gofaiss_IndexHNSW_neighbors(hnswPtr, C.idx_t(id), C.int(level),
+		(*C.idx_t)(&neighbors[0]), &n)
+	return neighbors[:n], nil
+}
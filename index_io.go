@@ -5,29 +5,43 @@ package faiss
 #include <stdio.h>
 #include <faiss/c_api/index_io_c.h>
 #include <faiss/c_api/index_io_c_ex.h>
+
+#include "gofaiss.h"
 */
 import "C"
 import (
+	"runtime"
 	"unsafe"
 )
 
 // WriteIndex writes an index to a file.
 func WriteIndex(idx Index, filename string) error {
+	ptr := idx.cPtr()
+	if ptr == nil {
+		return ErrIndexClosed
+	}
+	defer runtime.KeepAlive(idx)
 	cfname := C.CString(filename)
 	defer C.free(unsafe.Pointer(cfname))
-	if c := C.faiss_write_index_fname(idx.cPtr(), cfname); c != 0 {
+	if c := C.faiss_write_index_fname(ptr, cfname); c != 0 {
 		return newFaissError(ErrWriteIndexFailed, getLastError(), int(c))
 	}
 	return nil
 }
 
 func WriteIndexIntoBuffer(idx Index) ([]byte, error) {
+	ptr := idx.cPtr()
+	if ptr == nil {
+		return nil, ErrIndexClosed
+	}
+	defer runtime.KeepAlive(idx)
+
 	// the values to be returned by the faiss APIs
 	tempBuf := (*C.uchar)(nil)
 	bufSize := C.size_t(0)
 
 	if c := C.faiss_write_index_buf(
-		idx.cPtr(),
+		ptr,
 		&bufSize,
 		&tempBuf,
 	); c != 0 {
@@ -39,7 +53,8 @@ func WriteIndexIntoBuffer(idx Index) ([]byte, error) {
 	// something that's present on the C memory space, so not available to go's
 	// GC. needs to be freed when its of no more use.
 
-	// todo: add checksum.
+	// no checksum is added here, WriteIndexEnveloped wraps the serialized
+	// index in a checksummed envelope.
 	// the content populated in the tempBuf is converted from *C.uchar to unsafe.Pointer
 	// and then the pointer is casted into a large byte slice which is then sliced
 	// to a length and capacity equal to bufSize returned across the cgo interface.
@@ -83,22 +98,54 @@ func ReadIndexFromBuffer(buf []byte, ioflags int) (*IndexImpl, error) {
 	ptr := (*C.uchar)(unsafe.Pointer(&buf[0]))
 	size := C.size_t(len(buf))
 
-	// the idx var has C.FaissIndex within the struct which is nil as of now.
-	var idx faissIndex
+	// the idx var is a C.FaissIndex pointer which is nil as of now.
+	var idx *C.FaissIndex
 	if c := C.faiss_read_index_buf(ptr,
 		size,
 		C.int(ioflags),
-		&idx.idx); c != 0 {
+		&idx); c != 0 {
 		return nil, newFaissError(ErrReadIndexFailed, getLastError(), int(c))
 	}
 
 	ptr = nil
 
 	// after exiting the faiss_read_index_buf, the ref count to the memory allocated
-	// for the freshly created faiss::index becomes 1 (held by idx.idx of type C.FaissIndex)
+	// for the freshly created faiss::index becomes 1 (held by idx of type C.FaissIndex)
 	// this is allocated on the C heap, so not available for golang's GC. hence needs
 	// to be cleaned up after the index is longer being used - to be done at zap layer.
-	return &IndexImpl{&idx}, nil
+	return &IndexImpl{newFaissIndex(idx)}, nil
+}
+
+// ReadIndexFromMappedBuffer is like ReadIndexFromBuffer, but the returned
+// index references the vector codes and inverted lists held by buf in place
+// instead of copying them, which suits buffers that are memory-mapped files.
+// buf is pinned until the index is closed and must not be modified or
+// unmapped before then. As it does not own its data, the index is read-only:
+// the methods modifying it return ErrIndexReadOnly.
+func ReadIndexFromMappedBuffer(buf []byte, ioflags int) (*IndexImpl, error) {
+	if len(buf) == 0 {
+		return nil, ErrEmptyInput
+	}
+	pinner := new(runtime.Pinner)
+	pinner.Pin(&buf[0])
+
+	var ptr *C.FaissIndex
+	if c := C.gofaiss_read_index_mapped(
+		(*C.uint8_t)(unsafe.Pointer(&buf[0])),
+		C.size_t(len(buf)),
+		C.int(ioflags),
+		&ptr,
+	); c != 0 {
+		pinner.Unpin()
+		return nil, newFaissError(ErrReadIndexFailed, getLastError(), int(c))
+	}
+
+	idx := &faissIndex{idx: ptr, readOnly: true, pinner: pinner}
+	idx.cleanup = addLeakCleanup(idx, "faiss index", func() {
+		C.faiss_Index_free(ptr)
+		pinner.Unpin()
+	})
+	return &IndexImpl{idx}, nil
 }
 
 const (
@@ -112,20 +159,26 @@ const (
 func ReadIndex(filename string, ioflags int) (*IndexImpl, error) {
 	cfname := C.CString(filename)
 	defer C.free(unsafe.Pointer(cfname))
-	var idx faissIndex
-	if c := C.faiss_read_index_fname(cfname, C.int(ioflags), &idx.idx); c != 0 {
+	var idx *C.FaissIndex
+	if c := C.faiss_read_index_fname(cfname, C.int(ioflags), &idx); c != 0 {
 		return nil, newFaissError(ErrReadIndexFailed, getLastError(), int(c))
 	}
-	return &IndexImpl{&idx}, nil
+	return &IndexImpl{newFaissIndex(idx)}, nil
 }
 
 func WriteBinaryIndexIntoBuffer(idx BinaryIndex) ([]byte, error) {
+	ptr := idx.bPtr()
+	if ptr == nil {
+		return nil, ErrIndexClosed
+	}
+	defer runtime.KeepAlive(idx)
+
 	// the values to be returned by the faiss APIs
 	tempBuf := (*C.uchar)(nil)
 	bufSize := C.size_t(0)
 
 	if c := C.faiss_write_index_binary_buf(
-		idx.bPtr(),
+		ptr,
 		&bufSize,
 		&tempBuf,
 	); c != 0 {
@@ -148,13 +201,41 @@ func ReadBinaryIndexFromBuffer(buf []byte, ioflags int) (*BinaryIndexImpl, error
 	ptr := (*C.uchar)(unsafe.Pointer(&buf[0]))
 	size := C.size_t(len(buf))
 
-	var bIdx faissBinaryIndex
+	var bIdx *C.FaissIndexBinary
 	if c := C.faiss_read_index_binary_buf(ptr,
 		size,
 		C.int(ioflags),
-		&bIdx.bIdx); c != 0 {
+		&bIdx); c != 0 {
 		return nil, newFaissError(ErrReadIndexFailed, getLastError(), int(c))
 	}
 
-	return &BinaryIndexImpl{&bIdx}, nil
+	return &BinaryIndexImpl{newFaissBinaryIndex(bIdx)}, nil
+}
+
+// WriteBinaryIndex writes a binary index to a file.
+func WriteBinaryIndex(idx BinaryIndex, filename string) error {
+	ptr := idx.bPtr()
+	if ptr == nil {
+		return ErrIndexClosed
+	}
+	defer runtime.KeepAlive(idx)
+	cfname := C.CString(filename)
+	defer C.free(unsafe.Pointer(cfname))
+	if c := C.faiss_write_index_binary_fname(ptr, cfname); c != 0 {
+		return newFaissError(ErrWriteIndexFailed, getLastError(), int(c))
+	}
+	return nil
+}
+
+// ReadBinaryIndex reads a binary index from a file. Like ReadIndex, it honors
+// IOFlagMmap, IOFlagReadOnly and IOFlagReadMmap, the latter memory-mapping the
+// inverted lists of binary IVF indexes rather than loading them.
+func ReadBinaryIndex(filename string, ioflags int) (*BinaryIndexImpl, error) {
+	cfname := C.CString(filename)
+	defer C.free(unsafe.Pointer(cfname))
+	var bIdx *C.FaissIndexBinary
+	if c := C.faiss_read_index_binary_fname(cfname, C.int(ioflags), &bIdx); c != 0 {
+		return nil, newFaissError(ErrReadIndexFailed, getLastError(), int(c))
+	}
+	return &BinaryIndexImpl{newFaissBinaryIndex(bIdx)}, nil
 }
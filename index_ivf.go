@@ -9,8 +9,13 @@ package faiss
 #include <faiss/c_api/IndexScalarQuantizer_c.h>
 */
 import "C"
+import "runtime"
 
 func (idx *faissIndex) SetDirectMap(mapType int) (err error) {
+	if idx.closed() {
+		return ErrIndexClosed
+	}
+	defer runtime.KeepAlive(idx)
 
 	ivfPtr := C.faiss_IndexIVF_cast(idx.cPtr())
 	if ivfPtr == nil {
@@ -25,7 +30,14 @@ func (idx *faissIndex) SetDirectMap(mapType int) (err error) {
 	return err
 }
 
+// GetSubIndex returns a view onto the sub-index of an IDMap2 index. The view
+// shares memory with idx, so closing it is a no-op and it becomes unusable
+// once idx is closed.
 func (idx *faissIndex) GetSubIndex() (Index, error) {
+	if idx.closed() {
+		return nil, ErrIndexClosed
+	}
+	defer runtime.KeepAlive(idx)
 
 	ptr := C.faiss_IndexIDMap2_cast(idx.cPtr())
 	if ptr == nil {
@@ -37,12 +49,16 @@ func (idx *faissIndex) GetSubIndex() (Index, error) {
 		return nil, ErrNotIDMapIndex
 	}
 
-	return &IndexImpl{&faissIndex{subIdx}}, nil
+	return &IndexImpl{&faissIndex{idx: subIdx, parent: idx}}, nil
 }
 
 // pass nprobe to be set as index time option for IVF indexes only.
 // varying nprobe impacts recall but with an increase in latency.
 func (idx *faissIndex) SetNProbe(nprobe int32) {
+	if idx.closed() {
+		return
+	}
+	defer runtime.KeepAlive(idx)
 	ivfPtr := C.faiss_IndexIVF_cast(idx.cPtr())
 	if ivfPtr == nil {
 		return
@@ -51,6 +67,10 @@ func (idx *faissIndex) SetNProbe(nprobe int32) {
 }
 
 func (idx *faissIndex) IVFParams() (nprobe, nlist int) {
+	if idx.closed() {
+		return 0, 0
+	}
+	defer runtime.KeepAlive(idx)
 	ivfPtr := C.faiss_IndexIVF_cast(idx.cPtr())
 	if ivfPtr == nil {
 		return 0, 0
@@ -60,11 +80,23 @@ func (idx *faissIndex) IVFParams() (nprobe, nlist int) {
 }
 
 func (idx *faissIndex) IsSQIndex() bool {
+	if idx.closed() {
+		return false
+	}
+	defer runtime.KeepAlive(idx)
 	sqPtr := C.faiss_IndexScalarQuantizer_cast(idx.cPtr())
 	return sqPtr != nil
 }
 
 func (idx *faissIndex) SetQuantizers(srcIndex Index) error {
+	if idx.closed() || srcIndex.cPtr() == nil {
+		return ErrIndexClosed
+	}
+	if err := idx.writable(); err != nil {
+		return err
+	}
+	defer runtime.KeepAlive(idx)
+	defer runtime.KeepAlive(srcIndex)
 	if !(idx.IsIVFIndex() && srcIndex.IsIVFIndex()) &&
 		!(idx.IsSQIndex() && srcIndex.IsSQIndex()) {
 		return ErrSetQuantizerNotSupported
@@ -0,0 +1,20 @@
+// Zero-copy deserialization of indexes, see ReadIndexFromMappedBuffer.
+
+#include "gofaiss.h"
+#include "gofaiss_impl.h"
+
+#include <faiss/impl/zerocopy_io.h>
+#include <faiss/index_io.h>
+
+int gofaiss_read_index_mapped(
+        const uint8_t* buf,
+        size_t size,
+        int io_flags,
+        FaissIndex** p_out) {
+    GOFAISS_TRY
+    // faiss creates views into the buffer of a ZeroCopyIOReader instead of
+    // copying the vectors it holds.
+    faiss::ZeroCopyIOReader reader(buf, size);
+    *p_out = reinterpret_cast<FaissIndex*>(faiss::read_index(&reader, io_flags));
+    GOFAISS_CATCH
+}
@@ -0,0 +1,168 @@
+// Streaming serialization of indexes, see index_stream.go.
+
+#include "gofaiss.h"
+#include "gofaiss_impl.h"
+
+#include <memory>
+
+#include <faiss/IndexScalarQuantizer.h>
+#include <faiss/impl/FaissAssert.h>
+#include <faiss/impl/ProductQuantizer.h>
+#include <faiss/impl/ScalarQuantizer.h>
+#include <faiss/impl/io.h>
+#include <faiss/index_io.h>
+
+namespace {
+
+// Writes to the Go io.Writer behind handle. faiss throws on short writes.
+struct GoIOWriter : faiss::IOWriter {
+    uintptr_t handle;
+
+    explicit GoIOWriter(uintptr_t handle) : handle(handle) {
+        name = "go-io-writer";
+    }
+
+    size_t operator()(const void* ptr, size_t size, size_t nitems) override {
+        if (size == 0 || nitems == 0) {
+            return nitems;
+        }
+        // cgo exports cannot take const pointers.
+        return goFaissStreamWrite(
+                       handle, const_cast<void*>(ptr), size * nitems) /
+                size;
+    }
+};
+
+// Reads from the Go io.Reader behind handle, never past the requested bytes.
+// faiss throws on short reads.
+struct GoIOReader : faiss::IOReader {
+    uintptr_t handle;
+
+    explicit GoIOReader(uintptr_t handle) : handle(handle) {
+        name = "go-io-reader";
+    }
+
+    size_t operator()(void* ptr, size_t size, size_t nitems) override {
+        if (size == 0 || nitems == 0) {
+            return nitems;
+        }
+        return goFaissStreamRead(handle, ptr, size * nitems) / size;
+    }
+};
+
+} // namespace
+
+int gofaiss_write_index_stream(
+        const FaissIndex* index,
+        uintptr_t handle,
+        int io_flags) {
+    GOFAISS_TRY
+    GoIOWriter writer(handle);
+    faiss::write_index(
+            reinterpret_cast<const faiss::Index*>(index), &writer, io_flags);
+    GOFAISS_CATCH
+}
+
+int gofaiss_write_index_binary_stream(
+        const FaissIndexBinary* index,
+        uintptr_t handle) {
+    GOFAISS_TRY
+    GoIOWriter writer(handle);
+    faiss::write_index_binary(
+            reinterpret_cast<const faiss::IndexBinary*>(index), &writer);
+    GOFAISS_CATCH
+}
+
+int gofaiss_write_VectorTransform_stream(
+        const FaissVectorTransform* vt,
+        uintptr_t handle) {
+    GOFAISS_TRY
+    GoIOWriter writer(handle);
+    faiss::write_VectorTransform(
+            reinterpret_cast<const faiss::VectorTransform*>(vt), &writer);
+    GOFAISS_CATCH
+}
+
+int gofaiss_read_index_stream(
+        uintptr_t handle,
+        int io_flags,
+        FaissIndex** p_out) {
+    GOFAISS_TRY
+    GoIOReader reader(handle);
+    *p_out = reinterpret_cast<FaissIndex*>(faiss::read_index(&reader, io_flags));
+    GOFAISS_CATCH
+}
+
+int gofaiss_read_index_binary_stream(
+        uintptr_t handle,
+        int io_flags,
+        FaissIndexBinary** p_out) {
+    GOFAISS_TRY
+    GoIOReader reader(handle);
+    *p_out = reinterpret_cast<FaissIndexBinary*>(
+            faiss::read_index_binary(&reader, io_flags));
+    GOFAISS_CATCH
+}
+
+int gofaiss_read_VectorTransform_stream(
+        uintptr_t handle,
+        FaissVectorTransform** p_out) {
+    GOFAISS_TRY
+    GoIOReader reader(handle);
+    *p_out = reinterpret_cast<FaissVectorTransform*>(
+            faiss::read_VectorTransform(&reader));
+    GOFAISS_CATCH
+}
+
+int gofaiss_write_ProductQuantizer_stream(
+        const GofaissProductQuantizer* pq,
+        uintptr_t handle) {
+    GOFAISS_TRY
+    GoIOWriter writer(handle);
+    faiss::write_ProductQuantizer(
+            reinterpret_cast<const faiss::ProductQuantizer*>(pq), &writer);
+    GOFAISS_CATCH
+}
+
+int gofaiss_read_ProductQuantizer_stream(
+        uintptr_t handle,
+        GofaissProductQuantizer** p_out) {
+    GOFAISS_TRY
+    GoIOReader reader(handle);
+    *p_out = reinterpret_cast<GofaissProductQuantizer*>(
+            faiss::read_ProductQuantizer(&reader));
+    GOFAISS_CATCH
+}
+
+// faiss does not expose the serialization of scalar quantizers on their own,
+// so they are serialized as an IndexScalarQuantizer without vectors.
+int gofaiss_write_ScalarQuantizer_stream(
+        const GofaissScalarQuantizer* sq,
+        uintptr_t handle) {
+    GOFAISS_TRY
+    auto* q = reinterpret_cast<const faiss::ScalarQuantizer*>(sq);
+    faiss::IndexScalarQuantizer index(q->d, q->qtype);
+    index.sq = *q;
+    // the constructor marks the index trained if the type needs no training.
+    index.is_trained = !q->trained.empty() || index.is_trained;
+    GoIOWriter writer(handle);
+    faiss::write_index(&index, &writer);
+    GOFAISS_CATCH
+}
+
+int gofaiss_read_ScalarQuantizer_stream(
+        uintptr_t handle,
+        GofaissScalarQuantizer** p_out,
+        int* is_trained) {
+    GOFAISS_TRY
+    GoIOReader reader(handle);
+    std::unique_ptr<faiss::Index> index(faiss::read_index(&reader));
+    auto* sqIndex = dynamic_cast<faiss::IndexScalarQuantizer*>(index.get());
+    if (sqIndex == nullptr) {
+        FAISS_THROW_MSG("not a serialized scalar quantizer");
+    }
+    *p_out = reinterpret_cast<GofaissScalarQuantizer*>(
+            new faiss::ScalarQuantizer(sqIndex->sq));
+    *is_trained = sqIndex->is_trained;
+    GOFAISS_CATCH
+}
@@ -0,0 +1,132 @@
+package faiss
+
+/*
+#include "gofaiss.h"
+*/
+import "C"
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"runtime/cgo"
+	"unsafe"
+)
+
+// indexStream is the Go side of a streaming serialization, reached from C
+// through a cgo.Handle. It records the first error of w or r, which faiss
+// only sees as a short write or read.
+type indexStream struct {
+	w   io.Writer
+	r   io.Reader
+	err error
+}
+
+// run calls f with a handle to s, returning the error of w or r if there was
+// one, and the faiss error otherwise.
+func (s *indexStream) run(errType error, f func(handle C.uintptr_t) C.int) error {
+	h := cgo.NewHandle(s)
+	defer h.Delete()
+	if c := f(C.uintptr_t(h)); c != 0 {
+		if s.err != nil {
+			return newFaissError(errType, s.err, int(c))
+		}
+		return newFaissError(errType, getLastError(), int(c))
+	}
+	return nil
+}
+
+// transfer calls f, recovering from a panic of w or r, which must not unwind
+// through the C++ frames of faiss.
+func (s *indexStream) transfer(f func() (int, error)) (n int) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	n, err := f()
+	if err != nil && s.err == nil {
+		s.err = err
+	}
+	return n
+}
+
+//export goFaissStreamWrite
+func goFaissStreamWrite(handle C.uintptr_t, ptr unsafe.Pointer, n C.size_t) C.size_t {
+	s := cgo.Handle(handle).Value().(*indexStream)
+	if s.err != nil {
+		return 0
+	}
+	buf := unsafe.Slice((*byte)(ptr), int(n))
+	return C.size_t(s.transfer(func() (int, error) {
+		return s.w.Write(buf)
+	}))
+}
+
+//export goFaissStreamRead
+func goFaissStreamRead(handle C.uintptr_t, ptr unsafe.Pointer, n C.size_t) C.size_t {
+	s := cgo.Handle(handle).Value().(*indexStream)
+	if s.err != nil {
+		return 0
+	}
+	buf := unsafe.Slice((*byte)(ptr), int(n))
+	return C.size_t(s.transfer(func() (int, error) {
+		return io.ReadFull(s.r, buf)
+	}))
+}
+
+// WriteIndexTo serializes an index into w as it goes, without first building
+// the whole serialized index in memory like WriteIndexIntoBuffer does.
+// faiss issues many small writes, so w should be buffered if it performs a
+// system call per write. Nothing is written to w once it returns an error,
+// and that error is wrapped in the returned one: errors.Is matches both it
+// and ErrWriteIndexFailed, and errors.Unwrap returns it.
+func WriteIndexTo(idx Index, w io.Writer) error {
+	ptr := idx.cPtr()
+	if ptr == nil {
+		return ErrIndexClosed
+	}
+	defer runtime.KeepAlive(idx)
+	s := &indexStream{w: w}
+	return s.run(ErrWriteIndexFailed, func(handle C.uintptr_t) C.int {
+		return C.gofaiss_write_index_stream(ptr, handle, 0)
+	})
+}
+
+// ReadIndexFrom deserializes an index from r, which is not read past the end
+// of the serialized index.
+// The mmap flags require a file and are not supported.
+func ReadIndexFrom(r io.Reader, ioflags int) (*IndexImpl, error) {
+	var idx *C.FaissIndex
+	s := &indexStream{r: r}
+	if err := s.run(ErrReadIndexFailed, func(handle C.uintptr_t) C.int {
+		return C.gofaiss_read_index_stream(handle, C.int(ioflags), &idx)
+	}); err != nil {
+		return nil, err
+	}
+	return &IndexImpl{newFaissIndex(idx)}, nil
+}
+
+// WriteBinaryIndexTo is the binary index counterpart of WriteIndexTo.
+func WriteBinaryIndexTo(idx BinaryIndex, w io.Writer) error {
+	ptr := idx.bPtr()
+	if ptr == nil {
+		return ErrIndexClosed
+	}
+	defer runtime.KeepAlive(idx)
+	s := &indexStream{w: w}
+	return s.run(ErrWriteIndexFailed, func(handle C.uintptr_t) C.int {
+		return C.gofaiss_write_index_binary_stream(ptr, handle)
+	})
+}
+
+// ReadBinaryIndexFrom is the binary index counterpart of ReadIndexFrom.
+func ReadBinaryIndexFrom(r io.Reader, ioflags int) (*BinaryIndexImpl, error) {
+	var idx *C.FaissIndexBinary
+	s := &indexStream{r: r}
+	if err := s.run(ErrReadIndexFailed, func(handle C.uintptr_t) C.int {
+		return C.gofaiss_read_index_binary_stream(handle, C.int(ioflags), &idx)
+	}); err != nil {
+		return nil, err
+	}
+	return &BinaryIndexImpl{newFaissBinaryIndex(idx)}, nil
+}
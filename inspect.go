@@ -0,0 +1,264 @@
+package faiss
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+)
+
+// IndexInfo describes a serialized index, as read from its header by
+// InspectIndexBuffer and InspectIndexFile.
+type IndexInfo struct {
+	// FourCC is the four character code faiss tags the index type with.
+	FourCC string
+	// Type names the index type in index_factory terms, such as "IVFFlat" or
+	// "IDMap2,IVFSQ", and is empty for the types which are not recognized.
+	Type      string
+	Binary    bool
+	D         int
+	Metric    int
+	Ntotal    int64
+	IsTrained bool
+	// IDMap is true if the index is wrapped in an IDMap or IDMap2, in which
+	// case the fields below describe the wrapped index.
+	IDMap bool
+	// Nlist and Nprobe are 0 for non-IVF indexes.
+	Nlist  int
+	Nprobe int
+	// CodeSize is the size in bytes of the code of a vector, 0 if it could
+	// not be determined from the header.
+	CodeSize  int
+	HasRaBitQ bool
+}
+
+// indexTypes maps the fourcc of the index types to their names.
+var indexTypes = map[string]string{
+	"IxF2": "Flat",
+	"IxFI": "Flat",
+	"IxFl": "Flat",
+	"IxSQ": "SQ",
+	"IwFl": "IVFFlat",
+	"IwSq": "IVFSQ",
+	"IxMp": "IDMap",
+	"IxM2": "IDMap2",
+	"IxPq": "PQ",
+	"IvPQ": "IVFPQ",
+	"IHNf": "HNSWFlat",
+	"IHNp": "HNSWPQ",
+	"IHNs": "HNSWSQ",
+	"Ixrq": "RaBitQ",
+	"Iwrq": "IVFRaBitQ",
+	"IxPT": "PreTransform",
+
+	"IBxF": "BFlat",
+	"IBwF": "BIVF",
+	"IBMp": "IDMap",
+	"IBM2": "IDMap2",
+	"IBHf": "BHNSW",
+	"IBHh": "BHash",
+	"IBHm": "BMultiHash",
+}
+
+// InspectIndexBuffer describes the index serialized in buf, as written by
+// WriteIndexIntoBuffer or WriteBinaryIndexIntoBuffer, without deserializing
+// it. Envelopes written by WriteIndexEnveloped are not supported, see
+// ParseEnvelopeHeader.
+func InspectIndexBuffer(buf []byte) (*IndexInfo, error) {
+	return inspectIndex(bytes.NewReader(buf))
+}
+
+// InspectIndexFile describes the index serialized in the file at path, as
+// written by WriteIndex or WriteBinaryIndex, reading only its headers.
+func InspectIndexFile(path string) (*IndexInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInspectIndexFailed, err)
+	}
+	defer f.Close()
+	return inspectIndex(f)
+}
+
+func inspectIndex(r io.Reader) (*IndexInfo, error) {
+	h := &headerReader{r: bufio.NewReader(r)}
+	info := &IndexInfo{}
+	h.index(info)
+	if h.err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInspectIndexFailed, h.err)
+	}
+	return info, nil
+}
+
+// headerReader decodes the headers of a serialized index, following the
+// layout of faiss' index_write.cpp. Decoding errors are sticky.
+type headerReader struct {
+	r   *bufio.Reader
+	err error
+}
+
+func (h *headerReader) read(n int) []byte {
+	buf := make([]byte, n)
+	if h.err == nil {
+		if _, err := io.ReadFull(h.r, buf); err != nil {
+			h.err = err
+		}
+	}
+	return buf
+}
+
+func (h *headerReader) skip(n uint64) {
+	if h.err != nil {
+		return
+	}
+	if n > math.MaxInt64 {
+		h.err = fmt.Errorf("invalid length %d", n)
+		return
+	}
+	if _, err := io.CopyN(io.Discard, h.r, int64(n)); err != nil {
+		h.err = err
+	}
+}
+
+func (h *headerReader) int32() int32 {
+	return int32(binary.LittleEndian.Uint32(h.read(4)))
+}
+
+func (h *headerReader) uint64() uint64 {
+	return binary.LittleEndian.Uint64(h.read(8))
+}
+
+func (h *headerReader) bool() bool {
+	return h.read(1)[0] != 0
+}
+
+// vector skips a std::vector of elements of elemSize bytes.
+func (h *headerReader) vector(elemSize uint64) {
+	n := h.uint64()
+	if h.err == nil && n > math.MaxInt64/elemSize {
+		h.err = fmt.Errorf("invalid vector length %d", n)
+	}
+	h.skip(n * elemSize)
+}
+
+// header decodes the header common to all float indexes.
+func (h *headerReader) header(info *IndexInfo) {
+	info.D = int(h.int32())
+	info.Ntotal = int64(h.uint64())
+	h.uint64() // unused
+	h.uint64() // unused
+	info.IsTrained = h.bool()
+	info.Metric = int(h.int32())
+	if info.Metric > MetricL2 {
+		h.read(4) // metric_arg
+	}
+}
+
+// binaryHeader decodes the header common to all binary indexes.
+func (h *headerReader) binaryHeader(info *IndexInfo) {
+	info.D = int(h.int32())
+	info.CodeSize = int(h.int32())
+	info.Ntotal = int64(h.uint64())
+	info.IsTrained = h.bool()
+	info.Metric = int(h.int32())
+}
+
+// scalarQuantizerCodeSize decodes a ScalarQuantizer, returning its code size.
+func (h *headerReader) scalarQuantizerCodeSize() int {
+	h.int32()  // qtype
+	h.int32()  // rangestat
+	h.read(4)  // rangestat_arg
+	h.uint64() // d
+	codeSize := int(h.uint64())
+	h.vector(4) // trained
+	return codeSize
+}
+
+// index decodes the headers of the index at the current position into info,
+// returning false if the index is not fully decoded, i.e. if the position is
+// not at its end.
+func (h *headerReader) index(info *IndexInfo) bool {
+	fourcc := string(h.read(4))
+	if h.err != nil {
+		return false
+	}
+	info.FourCC = fourcc
+	info.Type = indexTypes[fourcc]
+	info.Binary = strings.HasPrefix(fourcc, "IB")
+	if info.Binary {
+		return h.binaryIndex(info)
+	}
+
+	h.header(info)
+	switch fourcc {
+	case "IxF2", "IxFI", "IxFl":
+		info.CodeSize = 4 * info.D
+		// the size of the codes is stored in floats or bytes depending on
+		// the faiss version, but the codes of ntotal vectors follow either
+		// way.
+		h.uint64()
+		h.skip(uint64(info.Ntotal) * uint64(info.CodeSize))
+		return h.err == nil
+	case "IxSQ":
+		info.CodeSize = h.scalarQuantizerCodeSize()
+	case "IxMp", "IxM2":
+		h.idMap(info)
+	case "IwFl", "IwSq", "Iwrq", "IvPQ":
+		info.Nlist = int(h.uint64())
+		info.Nprobe = int(h.uint64())
+		info.HasRaBitQ = fourcc == "Iwrq"
+		switch fourcc {
+		case "IwFl":
+			info.CodeSize = 4 * info.D
+		case "IwSq":
+			// the scalar quantizer follows the coarse quantizer, which can
+			// only be skipped if it is fully decoded.
+			var quantizer IndexInfo
+			if h.index(&quantizer) {
+				h.directMap()
+				info.CodeSize = h.scalarQuantizerCodeSize()
+			}
+		}
+	case "Ixrq":
+		info.HasRaBitQ = true
+	}
+	return false
+}
+
+// idMap decodes the index wrapped by an IDMap, which follows its header.
+func (h *headerReader) idMap(info *IndexInfo) {
+	var sub IndexInfo
+	h.index(&sub)
+	info.IDMap = true
+	if info.Type != "" && sub.Type != "" {
+		info.Type += "," + sub.Type
+	} else {
+		info.Type = ""
+	}
+	info.Nlist, info.Nprobe = sub.Nlist, sub.Nprobe
+	info.CodeSize, info.HasRaBitQ = sub.CodeSize, sub.HasRaBitQ
+}
+
+// directMap skips the direct map of an IVF index.
+func (h *headerReader) directMap() {
+	mapType := h.read(1)[0]
+	h.vector(8) // array
+	if mapType == 2 {
+		h.vector(16) // hashtable entries
+	}
+}
+
+func (h *headerReader) binaryIndex(info *IndexInfo) bool {
+	h.binaryHeader(info)
+	switch info.FourCC {
+	case "IBwF":
+		info.Nlist = int(h.uint64())
+		info.Nprobe = int(h.uint64())
+	case "IBMp", "IBM2":
+		h.idMap(info)
+	}
+	return false
+}
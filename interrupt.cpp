@@ -0,0 +1,98 @@
+// Interruption of in-flight faiss calls, see interruptible in context.go.
+
+#include "gofaiss.h"
+
+#include <atomic>
+#include <memory>
+#include <mutex>
+
+#include <faiss/impl/AuxIndexStructures.h>
+
+struct GofaissInterruptToken {
+    // one of the token_* states below.
+    std::atomic<int> state{0};
+};
+
+namespace {
+
+const int token_running = 0;
+const int token_cancelled = 1;
+const int token_ended = 2;
+
+// number of tokens cancelled while their call is running, so that the
+// callback returns right away in the common case where there are none.
+std::atomic<int> cancelled_calls{0};
+
+// token of the call running on this thread, if any. faiss polls the callback
+// from the OpenMP worker threads too, which have none, but the calling
+// thread is the master thread of the parallel regions and polls it as well.
+thread_local GofaissInterruptToken* current_token = nullptr;
+
+// Installed while calls with a token are running, chaining to the callback
+// it replaced, if any.
+struct GoInterruptCallback : faiss::InterruptCallback {
+    std::unique_ptr<faiss::InterruptCallback> previous;
+
+    bool want_interrupt() override {
+        if (previous && previous->want_interrupt()) {
+            return true;
+        }
+        if (cancelled_calls.load(std::memory_order_relaxed) == 0) {
+            return false;
+        }
+        GofaissInterruptToken* token = current_token;
+        return token != nullptr &&
+                token->state.load(std::memory_order_relaxed) ==
+                token_cancelled;
+    }
+};
+
+// number of calls with a token running, guarded by the lock of faiss.
+int running_calls = 0;
+
+} // namespace
+
+GofaissInterruptToken* gofaiss_interrupt_token_new() {
+    return new GofaissInterruptToken();
+}
+
+void gofaiss_interrupt_token_free(GofaissInterruptToken* token) {
+    delete token;
+}
+
+void gofaiss_interrupt_token_cancel(GofaissInterruptToken* token) {
+    int expected = token_running;
+    if (token->state.compare_exchange_strong(expected, token_cancelled)) {
+        cancelled_calls.fetch_add(1);
+    }
+}
+
+void gofaiss_interrupt_begin(GofaissInterruptToken* token) {
+    {
+        std::lock_guard<std::mutex> guard(faiss::InterruptCallback::lock);
+        if (running_calls++ == 0) {
+            auto callback = new GoInterruptCallback();
+            callback->previous = std::move(faiss::InterruptCallback::instance);
+            faiss::InterruptCallback::instance.reset(callback);
+        }
+    }
+    current_token = token;
+}
+
+int gofaiss_interrupt_end(GofaissInterruptToken* token) {
+    current_token = nullptr;
+    {
+        std::lock_guard<std::mutex> guard(faiss::InterruptCallback::lock);
+        auto& instance = faiss::InterruptCallback::instance;
+        // the callback is left alone if the application replaced it.
+        auto callback = dynamic_cast<GoInterruptCallback*>(instance.get());
+        if (--running_calls == 0 && callback != nullptr) {
+            instance = std::move(callback->previous);
+        }
+    }
+    if (token->state.exchange(token_ended) == token_cancelled) {
+        cancelled_calls.fetch_sub(1);
+        return 1;
+    }
+    return 0;
+}
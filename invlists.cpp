@@ -0,0 +1,140 @@
+// Inverted lists of IVF indexes, see invlists.go.
+
+#include "gofaiss.h"
+#include "gofaiss_impl.h"
+
+#include <cstring>
+
+#include <faiss/IndexBinaryIVF.h>
+#include <faiss/IndexIVF.h>
+#include <faiss/impl/FaissAssert.h>
+#include <faiss/invlists/DirectMap.h>
+#include <faiss/invlists/InvertedLists.h>
+
+using faiss::DirectMap;
+using faiss::InvertedLists;
+
+namespace {
+
+const InvertedLists* il_cast(const GofaissInvertedLists* il) {
+    return reinterpret_cast<const InvertedLists*>(il);
+}
+
+const DirectMap* dm_cast(const GofaissDirectMap* dm) {
+    return reinterpret_cast<const DirectMap*>(dm);
+}
+
+faiss::IndexIVF* ivf_index(FaissIndex* index) {
+    return dynamic_cast<faiss::IndexIVF*>(
+            reinterpret_cast<faiss::Index*>(index));
+}
+
+faiss::IndexBinaryIVF* bivf_index(FaissIndexBinary* index) {
+    return dynamic_cast<faiss::IndexBinaryIVF*>(
+            reinterpret_cast<faiss::IndexBinary*>(index));
+}
+
+} // namespace
+
+GofaissInvertedLists* gofaiss_IndexIVF_invlists(FaissIndex* index) {
+    faiss::IndexIVF* ivf = ivf_index(index);
+    return ivf ? reinterpret_cast<GofaissInvertedLists*>(ivf->invlists)
+               : nullptr;
+}
+
+GofaissDirectMap* gofaiss_IndexIVF_direct_map(FaissIndex* index) {
+    faiss::IndexIVF* ivf = ivf_index(index);
+    return ivf ? reinterpret_cast<GofaissDirectMap*>(&ivf->direct_map)
+               : nullptr;
+}
+
+GofaissInvertedLists* gofaiss_IndexBinaryIVF_invlists(FaissIndexBinary* index) {
+    faiss::IndexBinaryIVF* ivf = bivf_index(index);
+    return ivf ? reinterpret_cast<GofaissInvertedLists*>(ivf->invlists)
+               : nullptr;
+}
+
+GofaissDirectMap* gofaiss_IndexBinaryIVF_direct_map(FaissIndexBinary* index) {
+    faiss::IndexBinaryIVF* ivf = bivf_index(index);
+    return ivf ? reinterpret_cast<GofaissDirectMap*>(&ivf->direct_map)
+               : nullptr;
+}
+
+size_t gofaiss_InvertedLists_nlist(const GofaissInvertedLists* il) {
+    return il_cast(il)->nlist;
+}
+
+size_t gofaiss_InvertedLists_code_size(const GofaissInvertedLists* il) {
+    size_t code_size = il_cast(il)->code_size;
+    return code_size == InvertedLists::INVALID_CODE_SIZE ? 0 : code_size;
+}
+
+int gofaiss_InvertedLists_list_size(
+        const GofaissInvertedLists* il,
+        size_t list_no,
+        size_t* size) {
+    GOFAISS_TRY
+    *size = il_cast(il)->list_size(list_no);
+    GOFAISS_CATCH
+}
+
+int gofaiss_InvertedLists_copy_list(
+        const GofaissInvertedLists* il,
+        size_t list_no,
+        size_t n,
+        idx_t* ids,
+        uint8_t* codes) {
+    GOFAISS_TRY
+    const InvertedLists* invlists = il_cast(il);
+    size_t size = invlists->list_size(list_no);
+    FAISS_THROW_IF_NOT_FMT(
+            size == n,
+            "list %zd holds %zd entries, expected %zd",
+            list_no,
+            size,
+            n);
+    if (ids != nullptr && n > 0) {
+        InvertedLists::ScopedIds scoped(invlists, list_no);
+        std::memcpy(ids, scoped.get(), n * sizeof(idx_t));
+    }
+    if (codes != nullptr && n > 0) {
+        FAISS_THROW_IF_NOT_MSG(
+                invlists->code_size != InvertedLists::INVALID_CODE_SIZE,
+                "inverted lists do not store codes contiguously");
+        InvertedLists::ScopedCodes scoped(invlists, list_no);
+        std::memcpy(codes, scoped.get(), n * invlists->code_size);
+    }
+    GOFAISS_CATCH
+}
+
+int gofaiss_DirectMap_type(const GofaissDirectMap* dm) {
+    return dm_cast(dm)->type;
+}
+
+// DirectMap::get throws for missing IDs, which are reported as -1 here
+// instead.
+
+void gofaiss_DirectMap_lookup(
+        const GofaissDirectMap* dm,
+        idx_t id,
+        idx_t* list_no,
+        idx_t* offset) {
+    const DirectMap* direct_map = dm_cast(dm);
+    idx_t lo = -1;
+    if (direct_map->type == DirectMap::Array) {
+        if (id >= 0 && size_t(id) < direct_map->array.size()) {
+            lo = direct_map->array[id];
+        }
+    } else if (direct_map->type == DirectMap::Hashtable) {
+        auto it = direct_map->hashtable.find(id);
+        if (it != direct_map->hashtable.end()) {
+            lo = it->second;
+        }
+    }
+    if (lo < 0) {
+        *list_no = *offset = -1;
+        return;
+    }
+    *list_no = faiss::lo_listno(lo);
+    *offset = faiss::lo_offset(lo);
+}
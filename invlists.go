@@ -0,0 +1,227 @@
+package faiss
+
+/*
+#include "gofaiss.h"
+*/
+import "C"
+import (
+	"fmt"
+	"iter"
+	"runtime"
+)
+
+// InvertedLists is a read-only view onto the inverted lists of an IVF index,
+// float or binary, as returned by their InvertedLists method. Each list holds
+// the IDs and the codes of the vectors assigned to one centroid.
+//
+// The view shares memory with the index, so it has nothing to free, and its
+// methods return ErrIndexClosed once the index is closed. Everything it
+// returns is a copy, which remains valid after the index is modified.
+//
+// The IDs are those stored by the IVF index itself. On the sub-index of an
+// IDMap2 index, as returned by GetSubIndex, they are the sequential internal
+// IDs the IDMap2 index assigned, not the IDs it was added with, which it
+// alone maps them to.
+type InvertedLists struct {
+	// the index the lists belong to, one of them being set. The lists are
+	// looked up on each call, since ReplaceCentroids replaces them.
+	index  *faissIndex
+	binary *faissBinaryIndex
+}
+
+// lists returns the inverted lists and the direct map of the index, or nil
+// once it has been closed. The caller must keep l alive while using them.
+func (l *InvertedLists) lists() (*C.GofaissInvertedLists, *C.GofaissDirectMap) {
+	if l.index != nil {
+		if l.index.closed() {
+			return nil, nil
+		}
+		return C.gofaiss_IndexIVF_invlists(l.index.idx),
+			C.gofaiss_IndexIVF_direct_map(l.index.idx)
+	}
+	if l.binary.closed() {
+		return nil, nil
+	}
+	return C.gofaiss_IndexBinaryIVF_invlists(l.binary.bIdx),
+		C.gofaiss_IndexBinaryIVF_direct_map(l.binary.bIdx)
+}
+
+// InvertedListEntry is a vector stored in an inverted list, at the given
+// offset.
+type InvertedListEntry struct {
+	List   int
+	Offset int
+	ID     int64
+	Code   []uint8
+}
+
+func (idx *faissIndex) InvertedLists() (*InvertedLists, error) {
+	if idx.closed() {
+		return nil, ErrIndexClosed
+	}
+	defer runtime.KeepAlive(idx)
+	if C.gofaiss_IndexIVF_invlists(idx.idx) == nil {
+		return nil, ErrNotIVFIndex
+	}
+	return &InvertedLists{index: idx}, nil
+}
+
+func (b *faissBinaryIndex) InvertedLists() (*InvertedLists, error) {
+	if b.closed() {
+		return nil, ErrIndexClosed
+	}
+	defer runtime.KeepAlive(b)
+	if C.gofaiss_IndexBinaryIVF_invlists(b.bIdx) == nil {
+		return nil, ErrNotBIVFIndex
+	}
+	return &InvertedLists{binary: b}, nil
+}
+
+// Nlist returns the number of lists.
+func (l *InvertedLists) Nlist() int {
+	il, _ := l.lists()
+	if il == nil {
+		return 0
+	}
+	defer runtime.KeepAlive(l)
+	return int(C.gofaiss_InvertedLists_nlist(il))
+}
+
+// CodeSize returns the size of the code of each entry in bytes, which is 0
+// for the indexes which do not store one code per entry, such as fast-scan
+// indexes.
+func (l *InvertedLists) CodeSize() int {
+	il, _ := l.lists()
+	if il == nil {
+		return 0
+	}
+	defer runtime.KeepAlive(l)
+	return int(C.gofaiss_InvertedLists_code_size(il))
+}
+
+// validateList checks that list is the number of an existing list.
+func (l *InvertedLists) validateList(list int) error {
+	if nlist := l.Nlist(); list < 0 || list >= nlist {
+		return fmt.Errorf("%w: list %d, nlist=%d", ErrInvalidList, list, nlist)
+	}
+	return nil
+}
+
+// ListSize returns the number of entries of list.
+func (l *InvertedLists) ListSize(list int) (int, error) {
+	il, _ := l.lists()
+	if il == nil {
+		return 0, ErrIndexClosed
+	}
+	defer runtime.KeepAlive(l)
+	if err := l.validateList(list); err != nil {
+		return 0, err
+	}
+	var size C.size_t
+	if c := C.gofaiss_InvertedLists_list_size(il, C.size_t(list),
+		&size); c != 0 {
+		return 0, newFaissError(ErrInspectIndexFailed, getLastError(), int(c))
+	}
+	return int(size), nil
+}
+
+// readList copies the IDs of list if withIDs is set, and its codes if
+// withCodes is set.
+func (l *InvertedLists) readList(list int, withIDs, withCodes bool) (
+	[]int64, []uint8, error) {
+	il, _ := l.lists()
+	if il == nil {
+		return nil, nil, ErrIndexClosed
+	}
+	defer runtime.KeepAlive(l)
+	n, err := l.ListSize(list)
+	if err != nil || n == 0 {
+		return nil, nil, err
+	}
+	var ids []int64
+	var codes []uint8
+	var idsPtr *C.idx_t
+	var codesPtr *C.uint8_t
+	if withIDs {
+		ids = make([]int64, n)
+		idsPtr = (*C.idx_t)(&ids[0])
+	}
+	if withCodes {
+		codeSize := l.CodeSize()
+		if codeSize == 0 {
+			return nil, nil, fmt.Errorf("%w: the index does not store one code per entry",
+				ErrInspectIndexFailed)
+		}
+		codes = make([]uint8, n*codeSize)
+		codesPtr = (*C.uint8_t)(&codes[0])
+	}
+	if c := C.gofaiss_InvertedLists_copy_list(il, C.size_t(list),
+		C.size_t(n), idsPtr, codesPtr); c != 0 {
+		return nil, nil, newFaissError(ErrInspectIndexFailed, getLastError(), int(c))
+	}
+	return ids, codes, nil
+}
+
+// ListIDs returns the IDs of the entries of list, in order. These are
+// internal IDs on the sub-index of an IDMap2 index, see InvertedLists.
+func (l *InvertedLists) ListIDs(list int) ([]int64, error) {
+	ids, _, err := l.readList(list, true, false)
+	return ids, err
+}
+
+// ListCodes returns the codes of the entries of list, in order, CodeSize
+// bytes each. For binary indexes, the codes are the vectors themselves.
+func (l *InvertedLists) ListCodes(list int) ([]uint8, error) {
+	_, codes, err := l.readList(list, false, true)
+	return codes, err
+}
+
+// ListOf returns the list holding the vector with ID id and its offset in
+// that list. It relies on the direct map of the index, see SetDirectMap, and
+// returns ErrNoDirectMap if there is none, or ErrIDNotFound if id is not in
+// the index. id is an internal ID on the sub-index of an IDMap2 index, see
+// InvertedLists.
+func (l *InvertedLists) ListOf(id int64) (list, offset int, err error) {
+	_, dm := l.lists()
+	if dm == nil {
+		return 0, 0, ErrIndexClosed
+	}
+	defer runtime.KeepAlive(l)
+	if C.gofaiss_DirectMap_type(dm) == 0 {
+		return 0, 0, ErrNoDirectMap
+	}
+	var cList, cOffset C.idx_t
+	C.gofaiss_DirectMap_lookup(dm, C.idx_t(id), &cList, &cOffset)
+	if cList < 0 {
+		return 0, 0, fmt.Errorf("%w: %d", ErrIDNotFound, id)
+	}
+	return int(cList), int(cOffset), nil
+}
+
+// All iterates over the entries of all the lists, list by list, reading one
+// list at a time. Code is nil for the indexes which do not store one code per
+// entry. If reading a list fails, the error is yielded with a zero entry and
+// the iteration stops. The IDs are internal IDs on the sub-index of an
+// IDMap2 index, see InvertedLists.
+func (l *InvertedLists) All() iter.Seq2[InvertedListEntry, error] {
+	return func(yield func(InvertedListEntry, error) bool) {
+		codeSize := l.CodeSize()
+		withCodes := codeSize > 0
+		for list := range l.Nlist() {
+			ids, codes, err := l.readList(list, true, withCodes)
+			if err != nil {
+				yield(InvertedListEntry{}, err)
+				return
+			}
+			for i, id := range ids {
+				entry := InvertedListEntry{List: list, Offset: i, ID: id}
+				if withCodes {
+					entry.Code = codes[i*codeSize : (i+1)*codeSize : (i+1)*codeSize]
+				}
+				if !yield(entry, nil) {
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package faiss
+
+/*
+#include <faiss/c_api/IndexIVF_c.h>
+#include <faiss/c_api/IndexIVF_c_ex.h>
+#include "gofaiss.h"
+*/
+import "C"
+import (
+	"fmt"
+	"runtime"
+)
+
+// ReplaceCentroids reconstructs all the stored vectors to assign them to the
+// new lists, so it temporarily needs memory for Ntotal() full vectors. Codes
+// which are not relative to their centroid, such as those of IVFFlat
+// indexes, are moved as is, while the residual codes of the others, including
+// IVFSQ and IVFPQ indexes by default, are encoded again.
+//
+// The coarse quantizer is modified in place, which affects the indexes it is
+// shared with, see SetQuantizers. If faiss fails halfway through, the index
+// can be left without some of its vectors.
+func (idx *faissIndex) ReplaceCentroids(centroids []float32) error {
+	if err := idx.writable(); err != nil {
+		return err
+	}
+	defer runtime.KeepAlive(idx)
+	if C.faiss_IndexIVF_cast(idx.idx) == nil {
+		return ErrNotIVFIndex
+	}
+	k, err := validateVectors(centroids, idx.D())
+	if err != nil {
+		return err
+	}
+	if c := C.gofaiss_IndexIVF_replace_centroids(idx.idx, C.idx_t(k),
+		(*C.float)(&centroids[0])); c != 0 {
+		return newFaissError(ErrReplaceCentroidsFailed, getLastError(), int(c))
+	}
+	return nil
+}
+
+// Lists holding fewer than maxListSize/rebalanceMinListDivisor vectors are
+// merged by Rebalance.
+const rebalanceMinListDivisor = 8
+
+// Rebalance splits each list holding n > maxListSize vectors into
+// ceil(n/maxListSize) lists, whose centroids are learned by a Kmeans over its
+// vectors, and removes the centroids of the lists holding fewer than
+// maxListSize/8 vectors, whose vectors thus go to the nearest remaining
+// lists. The vectors are then redistributed by ReplaceCentroids, so the
+// resulting lists are only approximately bounded by maxListSize.
+//
+// An index whose lists are all small is left unchanged.
+func (idx *faissIndex) Rebalance(maxListSize int) (split, merged int, err error) {
+	if err := idx.writable(); err != nil {
+		return 0, 0, err
+	}
+	defer runtime.KeepAlive(idx)
+	ivfPtr := C.faiss_IndexIVF_cast(idx.idx)
+	if ivfPtr == nil {
+		return 0, 0, ErrNotIVFIndex
+	}
+	if maxListSize <= 0 {
+		return 0, 0, fmt.Errorf("%w: maxListSize must be positive, got %d",
+			ErrRebalanceFailed, maxListSize)
+	}
+	d := idx.D()
+	nlist := int(C.faiss_IndexIVF_nlist(ivfPtr))
+	if nlist == 0 {
+		return 0, 0, nil
+	}
+	cardinalities := make([]C.size_t, nlist)
+	centroids := make([]float32, nlist*d)
+	if c := C.faiss_IndexIVF_get_centroids_and_cardinality(
+		idx.idx,
+		(*C.float)(&centroids[0]),
+		(*C.size_t)(&cardinalities[0]),
+		nil,
+	); c != 0 {
+		return 0, 0, newFaissError(ErrInspectIndexFailed, getLastError(), int(c))
+	}
+
+	params := DefaultKmeansParams()
+	params.Spherical = idx.MetricType() == MetricInnerProduct
+	minListSize := maxListSize / rebalanceMinListDivisor
+	newCentroids := make([]float32, 0, len(centroids))
+	for list, cardinality := range cardinalities {
+		n := int(cardinality)
+		switch {
+		case n > maxListSize:
+			listCentroids, err := idx.splitList(list, n,
+				(n+maxListSize-1)/maxListSize, params)
+			if err != nil {
+				return 0, 0, err
+			}
+			newCentroids = append(newCentroids, listCentroids...)
+			split++
+		case n < minListSize:
+			merged++
+		default:
+			newCentroids = append(newCentroids, centroids[list*d:(list+1)*d]...)
+		}
+	}
+	if (split == 0 && merged == 0) || len(newCentroids) == 0 {
+		return 0, 0, nil
+	}
+	if err := idx.ReplaceCentroids(newCentroids); err != nil {
+		return 0, 0, err
+	}
+	return split, merged, nil
+}
+
+// splitList returns k centroids for the n vectors of list.
+func (idx *faissIndex) splitList(list, n, k int, params KmeansParams) (
+	[]float32, error) {
+	x := make([]float32, n*idx.D())
+	if c := C.gofaiss_IndexIVF_reconstruct_list(idx.idx, C.size_t(list),
+		C.size_t(n), (*C.float)(&x[0])); c != 0 {
+		return nil, newFaissError(ErrReconstructFailed, getLastError(), int(c))
+	}
+	km, err := NewKmeans(idx.D(), k, params)
+	if err != nil {
+		return nil, err
+	}
+	defer km.Close()
+	if err := km.Train(x); err != nil {
+		return nil, fmt.Errorf("%w: splitting list %d: %w", ErrRebalanceFailed,
+			list, err)
+	}
+	return km.Centroids(), nil
+}
@@ -0,0 +1,248 @@
+package faiss
+
+/*
+#include "gofaiss.h"
+*/
+import "C"
+import (
+	"fmt"
+	"runtime"
+	"unsafe"
+)
+
+// KmeansParams configures the training of a Kmeans.
+// NewKmeans replaces a zero Niter, Nredo or MaxPointsPerCentroid with the
+// faiss default, see DefaultKmeansParams, so that KmeansParams{} may be
+// passed for the defaults with a zero Seed.
+type KmeansParams struct {
+	// Niter is the number of iterations of each run.
+	Niter int
+	// Nredo is the number of runs, the centroids of the run with the best
+	// objective being kept.
+	Nredo int
+	// Seed seeds the random number generator.
+	Seed int
+	// Spherical normalizes the centroids after each iteration, for
+	// clustering under the inner product metric.
+	Spherical bool
+	// The training set is subsampled to MaxPointsPerCentroid points per
+	// centroid, and a warning is logged by faiss if it has fewer than
+	// MinPointsPerCentroid points per centroid.
+	MinPointsPerCentroid int
+	MaxPointsPerCentroid int
+	// Verbose makes faiss print the progress of the training.
+	Verbose bool
+
+	// AssignIndex, if not nil, is used to assign the training vectors to
+	// their nearest centroid instead of a flat index, e.g. an HNSW index for
+	// large numbers of centroids. It must have the dimension of the vectors,
+	// and is reset and filled with the centroids by Train, after which it is
+	// also used by Assign. The Kmeans does not take ownership of it.
+	AssignIndex Index
+}
+
+// DefaultKmeansParams returns the faiss defaults.
+func DefaultKmeansParams() KmeansParams {
+	var cp C.FaissClusteringParameters
+	C.faiss_ClusteringParameters_init(&cp)
+	return KmeansParams{
+		Niter:                int(cp.niter),
+		Nredo:                int(cp.nredo),
+		Seed:                 int(cp.seed),
+		Spherical:            cp.spherical != 0,
+		MinPointsPerCentroid: int(cp.min_points_per_centroid),
+		MaxPointsPerCentroid: int(cp.max_points_per_centroid),
+		Verbose:              cp.verbose != 0,
+	}
+}
+
+func (p *KmeansParams) cParams() C.FaissClusteringParameters {
+	var cp C.FaissClusteringParameters
+	C.faiss_ClusteringParameters_init(&cp)
+	cp.niter = C.int(p.Niter)
+	cp.nredo = C.int(p.Nredo)
+	cp.seed = C.int(p.Seed)
+	cp.spherical = boolToCInt(p.Spherical)
+	cp.min_points_per_centroid = C.int(p.MinPointsPerCentroid)
+	cp.max_points_per_centroid = C.int(p.MaxPointsPerCentroid)
+	cp.verbose = boolToCInt(p.Verbose)
+	return cp
+}
+
+func boolToCInt(b bool) C.int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// KmeansIterationStats are the statistics of a k-means iteration.
+type KmeansIterationStats struct {
+	// Objective is the sum of the distances of the vectors to their
+	// centroid, or of their similarities for spherical k-means.
+	Objective float32
+	// Time and SearchTime are the seconds elapsed since the start of the
+	// run and spent assigning vectors to centroids.
+	Time       float64
+	SearchTime float64
+	// ImbalanceFactor is 1 when the clusters are of equal sizes.
+	ImbalanceFactor float64
+	// Nsplit is the number of clusters which were split for being empty.
+	Nsplit int
+}
+
+// Kmeans clusters vectors with the faiss implementation of k-means.
+type Kmeans struct {
+	d, k   int
+	params KmeansParams
+
+	// index holds the centroids once trained, and is owned by the Kmeans
+	// unless supplied with params.AssignIndex.
+	index     Index
+	ownsIndex bool
+
+	centroids []float32
+	stats     []KmeansIterationStats
+}
+
+// NewKmeans creates a Kmeans clustering vectors of dimension d into k
+// clusters.
+func NewKmeans(d, k int, params KmeansParams) (*Kmeans, error) {
+	if d <= 0 || k <= 0 {
+		return nil, fmt.Errorf("%w: d and k must be positive, got d=%d k=%d",
+			ErrCreateKmeansFailed, d, k)
+	}
+	if params.Niter < 0 || params.Nredo < 0 ||
+		params.MinPointsPerCentroid < 0 || params.MaxPointsPerCentroid < 0 {
+		return nil, fmt.Errorf("%w: Niter, Nredo, MinPointsPerCentroid and "+
+			"MaxPointsPerCentroid must not be negative", ErrCreateKmeansFailed)
+	}
+	defaults := DefaultKmeansParams()
+	if params.Niter == 0 {
+		params.Niter = defaults.Niter
+	}
+	if params.Nredo == 0 {
+		params.Nredo = defaults.Nredo
+	}
+	if params.MaxPointsPerCentroid == 0 {
+		params.MaxPointsPerCentroid = defaults.MaxPointsPerCentroid
+	}
+	if params.AssignIndex != nil && params.AssignIndex.D() != d {
+		return nil, fmt.Errorf("%w: assign index has dimension %d, expected %d",
+			ErrDimensionMismatch, params.AssignIndex.D(), d)
+	}
+	return &Kmeans{d: d, k: k, params: params}, nil
+}
+
+// D returns the dimension of the vectors.
+func (km *Kmeans) D() int {
+	return km.d
+}
+
+// K returns the number of clusters.
+func (km *Kmeans) K() int {
+	return km.k
+}
+
+// Train clusters the vectors in x, which must be at least k. Training again
+// replaces the centroids.
+func (km *Kmeans) Train(x []float32) error {
+	n, err := validateVectors(x, km.d)
+	if err != nil {
+		return err
+	}
+	if n < km.k {
+		return fmt.Errorf("%w: %d training vectors for %d clusters",
+			ErrTrainFailed, n, km.k)
+	}
+
+	index := km.params.AssignIndex
+	if index == nil {
+		if km.index == nil {
+			metric := MetricL2
+			if km.params.Spherical {
+				metric = MetricInnerProduct
+			}
+			flat, err := NewIndexFlat(km.d, metric)
+			if err != nil {
+				return err
+			}
+			km.index, km.ownsIndex = flat, true
+		}
+		index = km.index
+	}
+	indexPtr := index.cPtr()
+	if indexPtr == nil {
+		return ErrIndexClosed
+	}
+	defer runtime.KeepAlive(index)
+
+	cp := km.params.cParams()
+	var clustering *C.FaissClustering
+	if c := C.faiss_Clustering_new_with_params(&clustering, C.int(km.d),
+		C.int(km.k), &cp); c != 0 {
+		return newFaissError(ErrTrainFailed, getLastError(), int(c))
+	}
+	defer C.faiss_Clustering_free(clustering)
+
+	if c := C.faiss_Clustering_train(clustering, C.idx_t(n),
+		(*C.float)(&x[0]), indexPtr); c != 0 {
+		return newFaissError(ErrTrainFailed, getLastError(), int(c))
+	}
+
+	var centroids *C.float
+	var size C.size_t
+	C.faiss_Clustering_centroids(clustering, &centroids, &size)
+	km.centroids = make([]float32, size)
+	copy(km.centroids, unsafe.Slice((*float32)(unsafe.Pointer(centroids)), int(size)))
+
+	km.stats = nil
+	if nstats := C.gofaiss_Clustering_iteration_stats_size(clustering); nstats > 0 {
+		stats := make([]C.GofaissClusteringIterationStats, nstats)
+		C.gofaiss_Clustering_iteration_stats(clustering, &stats[0])
+		km.stats = make([]KmeansIterationStats, nstats)
+		for i, s := range stats {
+			km.stats[i] = KmeansIterationStats{
+				Objective:       float32(s.obj),
+				Time:            float64(s.time),
+				SearchTime:      float64(s.time_search),
+				ImbalanceFactor: float64(s.imbalance_factor),
+				Nsplit:          int(s.nsplit),
+			}
+		}
+	}
+	km.index = index
+	return nil
+}
+
+// Centroids returns the k centroids, one after the other, or nil if the
+// Kmeans has not been trained.
+func (km *Kmeans) Centroids() []float32 {
+	return km.centroids
+}
+
+// IterationStats returns the statistics of the iterations of all the runs
+// of the last training, one after the other.
+func (km *Kmeans) IterationStats() []KmeansIterationStats {
+	return km.stats
+}
+
+// Assign returns the nearest centroid of each vector in x, and its distance
+// to the vector.
+func (km *Kmeans) Assign(x []float32) (labels []int64, distances []float32, err error) {
+	if km.centroids == nil {
+		return nil, nil, ErrNotTrained
+	}
+	distances, labels, err = km.index.Search(x, 1)
+	return labels, distances, err
+}
+
+// Close frees the assignment index, unless it was supplied with
+// KmeansParams.AssignIndex. It is safe to call Close more than once.
+func (km *Kmeans) Close() {
+	if km.ownsIndex {
+		km.index.Close()
+		km.index, km.ownsIndex = nil, false
+	}
+	km.centroids = nil
+}
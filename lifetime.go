@@ -0,0 +1,22 @@
+package faiss
+
+import (
+	"log"
+	"runtime"
+)
+
+// addLeakCleanup registers a safety net on owner which frees its underlying
+// C memory if owner becomes unreachable without Close/Delete having been
+// called. The leak is logged so that it can be tracked down, since relying on
+// the garbage collector to release (potentially very large) C allocations
+// makes memory usage unpredictable.
+//
+// free must not reference owner, otherwise owner will never be collected.
+// The returned Cleanup must be stopped once the memory is freed explicitly.
+func addLeakCleanup[T any](owner *T, kind string, free func()) runtime.Cleanup {
+	return runtime.AddCleanup(owner, func(free func()) {
+		log.Printf("go-faiss: %s was garbage collected without being "+
+			"closed, freeing it", kind)
+		free()
+	}, free)
+}
@@ -0,0 +1,93 @@
+// Merging of the indexes faiss cannot merge by itself, see MergeFrom.
+
+#include "gofaiss.h"
+#include "gofaiss_impl.h"
+
+#include <algorithm>
+#include <vector>
+
+#include <faiss/IndexFlat.h>
+#include <faiss/IndexHNSW.h>
+#include <faiss/IndexIDMap.h>
+#include <faiss/impl/FaissAssert.h>
+
+namespace {
+
+// number of vectors moved at a time from one HNSW index to another.
+const idx_t hnsw_merge_batch_size = 4096;
+
+// The graph of src cannot be grafted onto that of dst, so its vectors are
+// reconstructed and added to dst, which links them into its graph. src is
+// then emptied, as faiss does when merging. Only flat storage is accepted,
+// as lossy storage such as SQ would encode the vectors decoded from src
+// again, losing precision. If adding fails, the vectors added so far remain
+// in dst, and src is left unchanged.
+void merge_hnsw(faiss::IndexHNSW* dst, faiss::Index* src) {
+    auto src_hnsw = dynamic_cast<faiss::IndexHNSW*>(src);
+    FAISS_THROW_IF_NOT_MSG(src_hnsw, "can only merge HNSW indexes together");
+    FAISS_THROW_IF_NOT(src_hnsw->d == dst->d);
+    FAISS_THROW_IF_NOT(src_hnsw->metric_type == dst->metric_type);
+    FAISS_THROW_IF_NOT_MSG(
+            dynamic_cast<faiss::IndexFlat*>(dst->storage) &&
+                    dynamic_cast<faiss::IndexFlat*>(src_hnsw->storage),
+            "can only merge HNSW indexes with flat storage");
+    std::vector<float> x(hnsw_merge_batch_size * dst->d);
+    for (idx_t i0 = 0; i0 < src_hnsw->ntotal; i0 += hnsw_merge_batch_size) {
+        idx_t ni = std::min(hnsw_merge_batch_size, src_hnsw->ntotal - i0);
+        src_hnsw->reconstruct_n(i0, ni, x.data());
+        dst->add(ni, x.data());
+    }
+    src_hnsw->reset();
+}
+
+// Like IndexIDMap2::merge_from, for an HNSW sub-index. If adding fails, the
+// IDs of the vectors added so far are mapped, so that dst stays consistent.
+void merge_idmap2_hnsw(
+        faiss::IndexIDMap2* dst,
+        faiss::IndexHNSW* dst_hnsw,
+        faiss::Index* src,
+        idx_t add_id) {
+    auto src_map = dynamic_cast<faiss::IndexIDMap2*>(src);
+    FAISS_THROW_IF_NOT_MSG(
+            src_map, "can only merge IDMap2 indexes together");
+    idx_t ntotal = dst_hnsw->ntotal;
+    auto map_added = [&]() {
+        for (idx_t i = 0; i < dst_hnsw->ntotal - ntotal; i++) {
+            dst->id_map.push_back(src_map->id_map[i] + add_id);
+        }
+        dst->ntotal = dst->index->ntotal;
+        dst->construct_rev_map();
+    };
+    try {
+        merge_hnsw(dst_hnsw, src_map->index);
+    } catch (...) {
+        map_added();
+        throw;
+    }
+    map_added();
+    src_map->id_map.clear();
+    src_map->rev_map.clear();
+    src_map->ntotal = 0;
+}
+
+} // namespace
+
+int gofaiss_Index_merge_from(FaissIndex* index, FaissIndex* other, idx_t add_id) {
+    GOFAISS_TRY
+    faiss::Index* dst = reinterpret_cast<faiss::Index*>(index);
+    faiss::Index* src = reinterpret_cast<faiss::Index*>(other);
+    if (auto hnsw = dynamic_cast<faiss::IndexHNSW*>(dst)) {
+        FAISS_THROW_IF_NOT_MSG(
+                add_id == 0, "cannot shift the sequential IDs of HNSW indexes");
+        merge_hnsw(hnsw, src);
+        return 0;
+    }
+    if (auto idmap = dynamic_cast<faiss::IndexIDMap2*>(dst)) {
+        if (auto hnsw = dynamic_cast<faiss::IndexHNSW*>(idmap->index)) {
+            merge_idmap2_hnsw(idmap, hnsw, src, add_id);
+            return 0;
+        }
+    }
+    dst->merge_from(*src, add_id);
+    GOFAISS_CATCH
+}
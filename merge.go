@@ -0,0 +1,79 @@
+package faiss
+
+/*
+#include <faiss/c_api/IndexFlat_c.h>
+#include <faiss/c_api/MetaIndexes_c.h>
+#include "gofaiss.h"
+*/
+import "C"
+import (
+	"fmt"
+	"runtime"
+)
+
+// mergeable reports whether the vectors of other can be merged into idx,
+// which requires both to be of the same kind. IDMap2 indexes are mergeable
+// when their sub-indexes are.
+func (idx *faissIndex) mergeable(other *faissIndex) bool {
+	switch {
+	case idx.IsIVFIndex() && other.IsIVFIndex(),
+		idx.IsSQIndex() && other.IsSQIndex(),
+		idx.IsHNSWIndex() && other.IsHNSWIndex():
+		return true
+	case C.faiss_IndexFlat_cast(idx.cPtr()) != nil:
+		return C.faiss_IndexFlat_cast(other.cPtr()) != nil
+	}
+	idMap := C.faiss_IndexIDMap2_cast(idx.cPtr())
+	otherIDMap := C.faiss_IndexIDMap2_cast(other.cPtr())
+	if idMap == nil || otherIDMap == nil {
+		return false
+	}
+	sub := &faissIndex{idx: C.faiss_IndexIDMap2_sub_index(idMap), parent: idx}
+	otherSub := &faissIndex{idx: C.faiss_IndexIDMap2_sub_index(otherIDMap),
+		parent: other}
+	return sub.mergeable(otherSub)
+}
+
+func (idx *faissIndex) MergeFrom(other Index, add_id int64) (err error) {
+	if other == nil {
+		return ErrIndexNil
+	}
+	if idx.closed() || other.cPtr() == nil {
+		return ErrIndexClosed
+	}
+	if err := idx.writable(); err != nil {
+		return err
+	}
+	// faiss empties other, which must not be a view into read-only memory.
+	o := other.impl()
+	if err := o.writable(); err != nil {
+		return err
+	}
+	defer runtime.KeepAlive(idx)
+	defer runtime.KeepAlive(other)
+	if o == idx {
+		return fmt.Errorf("%w: cannot merge an index into itself",
+			ErrMergeFromFailed)
+	}
+	if !idx.mergeable(o) {
+		return ErrMergeFromNotSupported
+	}
+	if idx.D() != o.D() {
+		return fmt.Errorf("%w: other has d=%d, index has d=%d",
+			ErrDimensionMismatch, o.D(), idx.D())
+	}
+	if idx.MetricType() != o.MetricType() {
+		return fmt.Errorf("%w: other has metric %d, index has metric %d",
+			ErrMergeFromFailed, o.MetricType(), idx.MetricType())
+	}
+
+	if c := C.gofaiss_Index_merge_from(
+		idx.cPtr(),
+		o.cPtr(),
+		C.idx_t(add_id),
+	); c != 0 {
+		err = newFaissError(ErrMergeFromFailed, getLastError(), int(c))
+	}
+
+	return err
+}
@@ -0,0 +1,204 @@
+// Standalone product and scalar quantizers, see quantizer.go.
+
+#include "gofaiss.h"
+#include "gofaiss_impl.h"
+
+#include <memory>
+
+#include <faiss/impl/FaissAssert.h>
+#include <faiss/impl/ProductQuantizer.h>
+#include <faiss/impl/ScalarQuantizer.h>
+
+using faiss::ProductQuantizer;
+using faiss::ScalarQuantizer;
+
+static_assert(GOFAISS_QT_8bit == int(ScalarQuantizer::QT_8bit), "");
+static_assert(GOFAISS_QT_4bit == int(ScalarQuantizer::QT_4bit), "");
+static_assert(GOFAISS_QT_8bit_uniform == int(ScalarQuantizer::QT_8bit_uniform), "");
+static_assert(GOFAISS_QT_4bit_uniform == int(ScalarQuantizer::QT_4bit_uniform), "");
+static_assert(GOFAISS_QT_fp16 == int(ScalarQuantizer::QT_fp16), "");
+static_assert(GOFAISS_QT_8bit_direct == int(ScalarQuantizer::QT_8bit_direct), "");
+static_assert(GOFAISS_QT_6bit == int(ScalarQuantizer::QT_6bit), "");
+static_assert(GOFAISS_QT_bf16 == int(ScalarQuantizer::QT_bf16), "");
+
+namespace {
+
+ProductQuantizer* pq_cast(GofaissProductQuantizer* pq) {
+    return reinterpret_cast<ProductQuantizer*>(pq);
+}
+
+const ProductQuantizer* pq_cast(const GofaissProductQuantizer* pq) {
+    return reinterpret_cast<const ProductQuantizer*>(pq);
+}
+
+ScalarQuantizer* sq_cast(GofaissScalarQuantizer* sq) {
+    return reinterpret_cast<ScalarQuantizer*>(sq);
+}
+
+const ScalarQuantizer* sq_cast(const GofaissScalarQuantizer* sq) {
+    return reinterpret_cast<const ScalarQuantizer*>(sq);
+}
+
+} // namespace
+
+// ---- ProductQuantizer ----
+
+int gofaiss_ProductQuantizer_new(
+        GofaissProductQuantizer** p_pq,
+        size_t d,
+        size_t M,
+        size_t nbits) {
+    GOFAISS_TRY
+    *p_pq = reinterpret_cast<GofaissProductQuantizer*>(
+            new ProductQuantizer(d, M, nbits));
+    GOFAISS_CATCH
+}
+
+void gofaiss_ProductQuantizer_free(GofaissProductQuantizer* pq) {
+    delete pq_cast(pq);
+}
+
+size_t gofaiss_ProductQuantizer_d(const GofaissProductQuantizer* pq) {
+    return pq_cast(pq)->d;
+}
+
+size_t gofaiss_ProductQuantizer_M(const GofaissProductQuantizer* pq) {
+    return pq_cast(pq)->M;
+}
+
+size_t gofaiss_ProductQuantizer_nbits(const GofaissProductQuantizer* pq) {
+    return pq_cast(pq)->nbits;
+}
+
+size_t gofaiss_ProductQuantizer_ksub(const GofaissProductQuantizer* pq) {
+    return pq_cast(pq)->ksub;
+}
+
+size_t gofaiss_ProductQuantizer_code_size(const GofaissProductQuantizer* pq) {
+    return pq_cast(pq)->code_size;
+}
+
+int gofaiss_ProductQuantizer_train(
+        GofaissProductQuantizer* pq,
+        idx_t n,
+        const float* x) {
+    GOFAISS_TRY
+    pq_cast(pq)->train(n, x);
+    GOFAISS_CATCH
+}
+
+int gofaiss_ProductQuantizer_compute_codes(
+        const GofaissProductQuantizer* pq,
+        idx_t n,
+        const float* x,
+        uint8_t* codes) {
+    GOFAISS_TRY
+    pq_cast(pq)->compute_codes(x, codes, n);
+    GOFAISS_CATCH
+}
+
+int gofaiss_ProductQuantizer_decode(
+        const GofaissProductQuantizer* pq,
+        idx_t n,
+        const uint8_t* codes,
+        float* x) {
+    GOFAISS_TRY
+    pq_cast(pq)->decode(codes, x, n);
+    GOFAISS_CATCH
+}
+
+int gofaiss_ProductQuantizer_compute_distance_tables(
+        const GofaissProductQuantizer* pq,
+        FaissMetricType metric,
+        idx_t n,
+        const float* x,
+        float* tables) {
+    GOFAISS_TRY
+    switch (metric) {
+        case METRIC_L2:
+            pq_cast(pq)->compute_distance_tables(n, x, tables);
+            break;
+        case METRIC_INNER_PRODUCT:
+            pq_cast(pq)->compute_inner_prod_tables(n, x, tables);
+            break;
+        default:
+            FAISS_THROW_FMT("unsupported metric %d", int(metric));
+    }
+    GOFAISS_CATCH
+}
+
+// ---- ScalarQuantizer ----
+
+int gofaiss_ScalarQuantizer_new(
+        GofaissScalarQuantizer** p_sq,
+        size_t d,
+        int qtype) {
+    GOFAISS_TRY
+    *p_sq = reinterpret_cast<GofaissScalarQuantizer*>(new ScalarQuantizer(
+            d, static_cast<ScalarQuantizer::QuantizerType>(qtype)));
+    GOFAISS_CATCH
+}
+
+void gofaiss_ScalarQuantizer_free(GofaissScalarQuantizer* sq) {
+    delete sq_cast(sq);
+}
+
+size_t gofaiss_ScalarQuantizer_d(const GofaissScalarQuantizer* sq) {
+    return sq_cast(sq)->d;
+}
+
+int gofaiss_ScalarQuantizer_qtype(const GofaissScalarQuantizer* sq) {
+    return sq_cast(sq)->qtype;
+}
+
+size_t gofaiss_ScalarQuantizer_code_size(const GofaissScalarQuantizer* sq) {
+    return sq_cast(sq)->code_size;
+}
+
+int gofaiss_ScalarQuantizer_train(
+        GofaissScalarQuantizer* sq,
+        idx_t n,
+        const float* x) {
+    GOFAISS_TRY
+    sq_cast(sq)->train(n, x);
+    GOFAISS_CATCH
+}
+
+int gofaiss_ScalarQuantizer_compute_codes(
+        const GofaissScalarQuantizer* sq,
+        idx_t n,
+        const float* x,
+        uint8_t* codes) {
+    GOFAISS_TRY
+    sq_cast(sq)->compute_codes(x, codes, n);
+    GOFAISS_CATCH
+}
+
+int gofaiss_ScalarQuantizer_decode(
+        const GofaissScalarQuantizer* sq,
+        idx_t n,
+        const uint8_t* codes,
+        float* x) {
+    GOFAISS_TRY
+    sq_cast(sq)->decode(codes, x, n);
+    GOFAISS_CATCH
+}
+
+int gofaiss_ScalarQuantizer_compute_distances(
+        const GofaissScalarQuantizer* sq,
+        FaissMetricType metric,
+        const float* query,
+        idx_t n,
+        const uint8_t* codes,
+        float* distances) {
+    GOFAISS_TRY
+    std::unique_ptr<ScalarQuantizer::SQDistanceComputer> dc(
+            sq_cast(sq)->get_distance_computer(
+                    static_cast<faiss::MetricType>(metric)));
+    dc->set_query(query);
+    size_t code_size = sq_cast(sq)->code_size;
+    for (idx_t i = 0; i < n; i++) {
+        distances[i] = dc->query_to_code(codes + i * code_size);
+    }
+    GOFAISS_CATCH
+}
@@ -0,0 +1,498 @@
+package faiss
+
+/*
+#include "gofaiss.h"
+*/
+import "C"
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"runtime"
+)
+
+// validateCodes checks that codes holds a non-zero whole number of codes of
+// codeSize bytes and returns that number.
+func validateCodes(codes []byte, codeSize int) (int, error) {
+	if len(codes) == 0 {
+		return 0, ErrEmptyInput
+	}
+	if codeSize <= 0 || len(codes)%codeSize != 0 {
+		return 0, fmt.Errorf("%w: len(codes)=%d bytes, code size=%d",
+			ErrDimensionMismatch, len(codes), codeSize)
+	}
+	return len(codes) / codeSize, nil
+}
+
+// ProductQuantizer encodes vectors of dimension d by splitting them into M
+// sub-vectors, each encoded as the nbits bit index of its nearest centroid
+// among the 2^nbits learned by Train for its sub-space. It is the encoder of
+// PQ indexes, usable on its own.
+type ProductQuantizer struct {
+	pq      *C.GofaissProductQuantizer
+	trained bool
+
+	// cleanup frees pq if the quantizer is garbage collected without Close.
+	cleanup runtime.Cleanup
+}
+
+func newProductQuantizer(ptr *C.GofaissProductQuantizer, trained bool) *ProductQuantizer {
+	pq := &ProductQuantizer{pq: ptr, trained: trained}
+	pq.cleanup = addLeakCleanup(pq, "faiss product quantizer", func() {
+		C.gofaiss_ProductQuantizer_free(ptr)
+	})
+	return pq
+}
+
+// NewProductQuantizer creates a new product quantizer. d must be a multiple
+// of M.
+func NewProductQuantizer(d, M, nbits int) (*ProductQuantizer, error) {
+	if d <= 0 || M <= 0 || nbits <= 0 || d%M != 0 {
+		return nil, fmt.Errorf("%w: invalid product quantizer d=%d M=%d "+
+			"nbits=%d", ErrCreateQuantizerFailed, d, M, nbits)
+	}
+	var pq *C.GofaissProductQuantizer
+	if c := C.gofaiss_ProductQuantizer_new(&pq, C.size_t(d), C.size_t(M),
+		C.size_t(nbits)); c != 0 {
+		return nil, newFaissError(ErrCreateQuantizerFailed, getLastError(), int(c))
+	}
+	return newProductQuantizer(pq, false), nil
+}
+
+// D returns the dimension of the vectors.
+func (pq *ProductQuantizer) D() int {
+	if pq.pq == nil {
+		return 0
+	}
+	defer runtime.KeepAlive(pq)
+	return int(C.gofaiss_ProductQuantizer_d(pq.pq))
+}
+
+// M returns the number of sub-quantizers.
+func (pq *ProductQuantizer) M() int {
+	if pq.pq == nil {
+		return 0
+	}
+	defer runtime.KeepAlive(pq)
+	return int(C.gofaiss_ProductQuantizer_M(pq.pq))
+}
+
+// Nbits returns the number of bits of the code of each sub-vector.
+func (pq *ProductQuantizer) Nbits() int {
+	if pq.pq == nil {
+		return 0
+	}
+	defer runtime.KeepAlive(pq)
+	return int(C.gofaiss_ProductQuantizer_nbits(pq.pq))
+}
+
+// Ksub returns the number of centroids of each sub-quantizer, 2^Nbits.
+func (pq *ProductQuantizer) Ksub() int {
+	if pq.pq == nil {
+		return 0
+	}
+	defer runtime.KeepAlive(pq)
+	return int(C.gofaiss_ProductQuantizer_ksub(pq.pq))
+}
+
+// CodeSize returns the size in bytes of the code of a vector.
+func (pq *ProductQuantizer) CodeSize() int {
+	if pq.pq == nil {
+		return 0
+	}
+	defer runtime.KeepAlive(pq)
+	return int(C.gofaiss_ProductQuantizer_code_size(pq.pq))
+}
+
+// IsTrained returns true if the quantizer has been trained, or deserialized.
+func (pq *ProductQuantizer) IsTrained() bool {
+	return pq.pq != nil && pq.trained
+}
+
+// Train learns the centroids of the sub-quantizers from the vectors in x,
+// which should hold at least Ksub of them, and preferably many more.
+func (pq *ProductQuantizer) Train(x []float32) error {
+	if pq.pq == nil {
+		return ErrQuantizerClosed
+	}
+	defer runtime.KeepAlive(pq)
+	n, err := validateVectors(x, pq.D())
+	if err != nil {
+		return err
+	}
+	if c := C.gofaiss_ProductQuantizer_train(pq.pq, C.idx_t(n),
+		(*C.float)(&x[0])); c != 0 {
+		return newFaissError(ErrTrainFailed, getLastError(), int(c))
+	}
+	pq.trained = true
+	return nil
+}
+
+// Encode returns the codes of the vectors in x, one after the other.
+func (pq *ProductQuantizer) Encode(x []float32) ([]byte, error) {
+	if pq.pq == nil {
+		return nil, ErrQuantizerClosed
+	}
+	if !pq.trained {
+		return nil, ErrNotTrained
+	}
+	defer runtime.KeepAlive(pq)
+	n, err := validateVectors(x, pq.D())
+	if err != nil {
+		return nil, err
+	}
+	codes := make([]byte, n*pq.CodeSize())
+	if c := C.gofaiss_ProductQuantizer_compute_codes(pq.pq, C.idx_t(n),
+		(*C.float)(&x[0]), (*C.uint8_t)(&codes[0])); c != 0 {
+		return nil, newFaissError(ErrEncodeFailed, getLastError(), int(c))
+	}
+	return codes, nil
+}
+
+// Decode returns the approximations of the vectors encoded in codes.
+func (pq *ProductQuantizer) Decode(codes []byte) ([]float32, error) {
+	if pq.pq == nil {
+		return nil, ErrQuantizerClosed
+	}
+	if !pq.trained {
+		return nil, ErrNotTrained
+	}
+	defer runtime.KeepAlive(pq)
+	n, err := validateCodes(codes, pq.CodeSize())
+	if err != nil {
+		return nil, err
+	}
+	x := make([]float32, n*pq.D())
+	if c := C.gofaiss_ProductQuantizer_decode(pq.pq, C.idx_t(n),
+		(*C.uint8_t)(&codes[0]), (*C.float)(&x[0])); c != 0 {
+		return nil, newFaissError(ErrDecodeFailed, getLastError(), int(c))
+	}
+	return x, nil
+}
+
+// DistanceTables computes, for each query vector in x, the M x Ksub table of
+// the distances between its sub-vectors and the centroids of the
+// sub-quantizers, under MetricL2 or MetricInnerProduct. The tables are
+// returned one after the other.
+//
+// The distance between a query and an encoded vector is then approximated,
+// without decoding the vector, by the sum over the sub-quantizers m of
+// table[m*Ksub+code[m]], where code[m] is the nbits bit code of sub-vector m.
+// Squared L2 distances are returned for MetricL2, as faiss does.
+func (pq *ProductQuantizer) DistanceTables(x []float32, metric int) ([]float32, error) {
+	if pq.pq == nil {
+		return nil, ErrQuantizerClosed
+	}
+	if !pq.trained {
+		return nil, ErrNotTrained
+	}
+	defer runtime.KeepAlive(pq)
+	n, err := validateVectors(x, pq.D())
+	if err != nil {
+		return nil, err
+	}
+	tables := make([]float32, n*pq.M()*pq.Ksub())
+	if c := C.gofaiss_ProductQuantizer_compute_distance_tables(pq.pq,
+		C.FaissMetricType(metric), C.idx_t(n), (*C.float)(&x[0]),
+		(*C.float)(&tables[0])); c != 0 {
+		return nil, newFaissError(ErrSearchFailed, getLastError(), int(c))
+	}
+	return tables, nil
+}
+
+// Close frees the memory used by the quantizer. It is safe to call Close
+// more than once.
+func (pq *ProductQuantizer) Close() {
+	if pq.pq == nil {
+		return
+	}
+	pq.cleanup.Stop()
+	C.gofaiss_ProductQuantizer_free(pq.pq)
+	pq.pq = nil
+}
+
+// WriteProductQuantizerTo serializes a product quantizer into w, like
+// WriteIndexTo. faiss does not record whether a product quantizer is trained,
+// so it returns ErrNotTrained rather than serialize an untrained one.
+func WriteProductQuantizerTo(pq *ProductQuantizer, w io.Writer) error {
+	if pq.pq == nil {
+		return ErrQuantizerClosed
+	}
+	if !pq.trained {
+		return ErrNotTrained
+	}
+	defer runtime.KeepAlive(pq)
+	s := &indexStream{w: w}
+	return s.run(ErrWriteIndexFailed, func(handle C.uintptr_t) C.int {
+		return C.gofaiss_write_ProductQuantizer_stream(pq.pq, handle)
+	})
+}
+
+// ReadProductQuantizerFrom deserializes a product quantizer from r, like
+// ReadIndexFrom.
+func ReadProductQuantizerFrom(r io.Reader) (*ProductQuantizer, error) {
+	var pq *C.GofaissProductQuantizer
+	s := &indexStream{r: r}
+	if err := s.run(ErrReadIndexFailed, func(handle C.uintptr_t) C.int {
+		return C.gofaiss_read_ProductQuantizer_stream(handle, &pq)
+	}); err != nil {
+		return nil, err
+	}
+	return newProductQuantizer(pq, true), nil
+}
+
+// WriteProductQuantizerIntoBuffer serializes a product quantizer into a
+// buffer.
+func WriteProductQuantizerIntoBuffer(pq *ProductQuantizer) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := WriteProductQuantizerTo(pq, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ReadProductQuantizerFromBuffer deserializes a product quantizer written by
+// WriteProductQuantizerIntoBuffer.
+func ReadProductQuantizerFromBuffer(buf []byte) (*ProductQuantizer, error) {
+	return ReadProductQuantizerFrom(bytes.NewReader(buf))
+}
+
+// ScalarQuantizerType is the encoding of the components of the vectors by a
+// ScalarQuantizer.
+type ScalarQuantizerType int
+
+const (
+	// 8 and 4 bits per component, over the range of each component learned
+	// by Train.
+	QT8bit ScalarQuantizerType = C.GOFAISS_QT_8bit
+	QT4bit ScalarQuantizerType = C.GOFAISS_QT_4bit
+	// 8 and 4 bits per component, over the same range for all components.
+	QT8bitUniform ScalarQuantizerType = C.GOFAISS_QT_8bit_uniform
+	QT4bitUniform ScalarQuantizerType = C.GOFAISS_QT_4bit_uniform
+	// half-precision floats, which need no training.
+	QTfp16 ScalarQuantizerType = C.GOFAISS_QT_fp16
+	// the integer part of components in [0, 255], which needs no training.
+	QT8bitDirect ScalarQuantizerType = C.GOFAISS_QT_8bit_direct
+	// 6 bits per component, over the range of each component.
+	QT6bit ScalarQuantizerType = C.GOFAISS_QT_6bit
+	// bfloat16 floats, which need no training.
+	QTbf16 ScalarQuantizerType = C.GOFAISS_QT_bf16
+)
+
+// needsTraining returns true if quantizers of type t learn ranges.
+func (t ScalarQuantizerType) needsTraining() bool {
+	switch t {
+	case QTfp16, QT8bitDirect, QTbf16:
+		return false
+	}
+	return true
+}
+
+// ScalarQuantizer encodes each component of vectors of dimension d on its
+// own, as done by SQ indexes, usable on its own.
+type ScalarQuantizer struct {
+	sq      *C.GofaissScalarQuantizer
+	trained bool
+
+	// cleanup frees sq if the quantizer is garbage collected without Close.
+	cleanup runtime.Cleanup
+}
+
+func newScalarQuantizer(ptr *C.GofaissScalarQuantizer, trained bool) *ScalarQuantizer {
+	sq := &ScalarQuantizer{sq: ptr, trained: trained}
+	sq.cleanup = addLeakCleanup(sq, "faiss scalar quantizer", func() {
+		C.gofaiss_ScalarQuantizer_free(ptr)
+	})
+	return sq
+}
+
+// NewScalarQuantizer creates a new scalar quantizer.
+func NewScalarQuantizer(d int, qtype ScalarQuantizerType) (*ScalarQuantizer, error) {
+	if d <= 0 || qtype < QT8bit || qtype > QTbf16 {
+		return nil, fmt.Errorf("%w: invalid scalar quantizer d=%d type=%d",
+			ErrCreateQuantizerFailed, d, int(qtype))
+	}
+	var sq *C.GofaissScalarQuantizer
+	if c := C.gofaiss_ScalarQuantizer_new(&sq, C.size_t(d), C.int(qtype)); c != 0 {
+		return nil, newFaissError(ErrCreateQuantizerFailed, getLastError(), int(c))
+	}
+	return newScalarQuantizer(sq, !qtype.needsTraining()), nil
+}
+
+// D returns the dimension of the vectors.
+func (sq *ScalarQuantizer) D() int {
+	if sq.sq == nil {
+		return 0
+	}
+	defer runtime.KeepAlive(sq)
+	return int(C.gofaiss_ScalarQuantizer_d(sq.sq))
+}
+
+// Type returns the encoding of the components.
+func (sq *ScalarQuantizer) Type() ScalarQuantizerType {
+	if sq.sq == nil {
+		return 0
+	}
+	defer runtime.KeepAlive(sq)
+	return ScalarQuantizerType(C.gofaiss_ScalarQuantizer_qtype(sq.sq))
+}
+
+// CodeSize returns the size in bytes of the code of a vector.
+func (sq *ScalarQuantizer) CodeSize() int {
+	if sq.sq == nil {
+		return 0
+	}
+	defer runtime.KeepAlive(sq)
+	return int(C.gofaiss_ScalarQuantizer_code_size(sq.sq))
+}
+
+// IsTrained returns true if the quantizer has been trained, deserialized from
+// a trained one, or is of a type which needs no training.
+func (sq *ScalarQuantizer) IsTrained() bool {
+	return sq.sq != nil && sq.trained
+}
+
+// Train learns the range of the components from the vectors in x.
+func (sq *ScalarQuantizer) Train(x []float32) error {
+	if sq.sq == nil {
+		return ErrQuantizerClosed
+	}
+	defer runtime.KeepAlive(sq)
+	n, err := validateVectors(x, sq.D())
+	if err != nil {
+		return err
+	}
+	if c := C.gofaiss_ScalarQuantizer_train(sq.sq, C.idx_t(n),
+		(*C.float)(&x[0])); c != 0 {
+		return newFaissError(ErrTrainFailed, getLastError(), int(c))
+	}
+	sq.trained = true
+	return nil
+}
+
+// Encode returns the codes of the vectors in x, one after the other.
+func (sq *ScalarQuantizer) Encode(x []float32) ([]byte, error) {
+	if sq.sq == nil {
+		return nil, ErrQuantizerClosed
+	}
+	if !sq.trained {
+		return nil, ErrNotTrained
+	}
+	defer runtime.KeepAlive(sq)
+	n, err := validateVectors(x, sq.D())
+	if err != nil {
+		return nil, err
+	}
+	codes := make([]byte, n*sq.CodeSize())
+	if c := C.gofaiss_ScalarQuantizer_compute_codes(sq.sq, C.idx_t(n),
+		(*C.float)(&x[0]), (*C.uint8_t)(&codes[0])); c != 0 {
+		return nil, newFaissError(ErrEncodeFailed, getLastError(), int(c))
+	}
+	return codes, nil
+}
+
+// Decode returns the approximations of the vectors encoded in codes.
+func (sq *ScalarQuantizer) Decode(codes []byte) ([]float32, error) {
+	if sq.sq == nil {
+		return nil, ErrQuantizerClosed
+	}
+	if !sq.trained {
+		return nil, ErrNotTrained
+	}
+	defer runtime.KeepAlive(sq)
+	n, err := validateCodes(codes, sq.CodeSize())
+	if err != nil {
+		return nil, err
+	}
+	x := make([]float32, n*sq.D())
+	if c := C.gofaiss_ScalarQuantizer_decode(sq.sq, C.idx_t(n),
+		(*C.uint8_t)(&codes[0]), (*C.float)(&x[0])); c != 0 {
+		return nil, newFaissError(ErrDecodeFailed, getLastError(), int(c))
+	}
+	return x, nil
+}
+
+// Distances computes the distances between the query vector and the vectors
+// encoded in codes, under MetricL2 or MetricInnerProduct, without decoding
+// them. Squared L2 distances are returned for MetricL2, as faiss does.
+func (sq *ScalarQuantizer) Distances(query []float32, codes []byte, metric int) (
+	[]float32, error) {
+	if sq.sq == nil {
+		return nil, ErrQuantizerClosed
+	}
+	if !sq.trained {
+		return nil, ErrNotTrained
+	}
+	defer runtime.KeepAlive(sq)
+	if len(query) != sq.D() {
+		return nil, fmt.Errorf("%w: len(query)=%d, d=%d",
+			ErrDimensionMismatch, len(query), sq.D())
+	}
+	n, err := validateCodes(codes, sq.CodeSize())
+	if err != nil {
+		return nil, err
+	}
+	distances := make([]float32, n)
+	if c := C.gofaiss_ScalarQuantizer_compute_distances(sq.sq,
+		C.FaissMetricType(metric), (*C.float)(&query[0]), C.idx_t(n),
+		(*C.uint8_t)(&codes[0]), (*C.float)(&distances[0])); c != 0 {
+		return nil, newFaissError(ErrSearchFailed, getLastError(), int(c))
+	}
+	return distances, nil
+}
+
+// Close frees the memory used by the quantizer. It is safe to call Close
+// more than once.
+func (sq *ScalarQuantizer) Close() {
+	if sq.sq == nil {
+		return
+	}
+	sq.cleanup.Stop()
+	C.gofaiss_ScalarQuantizer_free(sq.sq)
+	sq.sq = nil
+}
+
+// WriteScalarQuantizerTo serializes a scalar quantizer into w, like
+// WriteIndexTo. faiss has no serialization format for scalar quantizers on
+// their own, so it is written as an empty SQ index, which
+// ReadScalarQuantizerFrom also accepts.
+func WriteScalarQuantizerTo(sq *ScalarQuantizer, w io.Writer) error {
+	if sq.sq == nil {
+		return ErrQuantizerClosed
+	}
+	defer runtime.KeepAlive(sq)
+	s := &indexStream{w: w}
+	return s.run(ErrWriteIndexFailed, func(handle C.uintptr_t) C.int {
+		return C.gofaiss_write_ScalarQuantizer_stream(sq.sq, handle)
+	})
+}
+
+// ReadScalarQuantizerFrom deserializes a scalar quantizer from r, like
+// ReadIndexFrom.
+func ReadScalarQuantizerFrom(r io.Reader) (*ScalarQuantizer, error) {
+	var sq *C.GofaissScalarQuantizer
+	var isTrained C.int
+	s := &indexStream{r: r}
+	if err := s.run(ErrReadIndexFailed, func(handle C.uintptr_t) C.int {
+		return C.gofaiss_read_ScalarQuantizer_stream(handle, &sq, &isTrained)
+	}); err != nil {
+		return nil, err
+	}
+	return newScalarQuantizer(sq, isTrained != 0), nil
+}
+
+// WriteScalarQuantizerIntoBuffer serializes a scalar quantizer into a
+// buffer.
+func WriteScalarQuantizerIntoBuffer(sq *ScalarQuantizer) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := WriteScalarQuantizerTo(sq, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ReadScalarQuantizerFromBuffer deserializes a scalar quantizer written by
+// WriteScalarQuantizerIntoBuffer.
+func ReadScalarQuantizerFromBuffer(buf []byte) (*ScalarQuantizer, error) {
+	return ReadScalarQuantizerFrom(bytes.NewReader(buf))
+}
@@ -0,0 +1,270 @@
+package faiss
+
+import (
+	"encoding/json"
+	"fmt"
+	"iter"
+	"runtime"
+	"sort"
+)
+
+// Neighbor is a single search result: the ID of an indexed vector and its
+// distance to the query vector.
+type Neighbor struct {
+	ID       int64
+	Distance float32
+}
+
+// BinaryNeighbor is a single binary search result, with the Hamming distance
+// of the indexed vector to the query vector.
+type BinaryNeighbor struct {
+	ID       int64
+	Distance int32
+}
+
+// MetricHigherIsBetter returns true if larger values of the metric denote
+// closer vectors, i.e. the metric is a similarity rather than a distance.
+func MetricHigherIsBetter(metric int) bool {
+	return metric == MetricInnerProduct
+}
+
+// SearchResults wraps the flat distances and labels returned by Search and
+// SearchWithOptions, which hold k entries per query padded with -1 labels
+// when fewer than k results were found.
+type SearchResults struct {
+	k         int64
+	distances []float32
+	labels    []int64
+	metric    int
+}
+
+// NewSearchResults wraps the output of a k-nearest-neighbor search performed
+// on an index with the given metric.
+func NewSearchResults(distances []float32, labels []int64, k int64, metric int) (
+	*SearchResults, error) {
+	if err := validateSearchResults(len(distances), len(labels), k); err != nil {
+		return nil, err
+	}
+	return &SearchResults{k: k, distances: distances, labels: labels, metric: metric}, nil
+}
+
+// SearchNeighbors runs SearchWithOptions on idx and wraps the result.
+func SearchNeighbors(idx Index, x []float32, k int64, sel Selector,
+	params json.RawMessage) (*SearchResults, error) {
+	distances, labels, err := idx.SearchWithOptions(x, k, sel, params)
+	if err != nil {
+		return nil, err
+	}
+	return NewSearchResults(distances, labels, k, idx.MetricType())
+}
+
+// Nq returns the number of queries.
+func (r *SearchResults) Nq() int {
+	return int(int64(len(r.labels)) / r.k)
+}
+
+// K returns the number of results requested per query.
+func (r *SearchResults) K() int64 {
+	return r.k
+}
+
+// HigherIsBetter returns true if larger distances denote closer vectors, as
+// is the case for the inner product metric.
+func (r *SearchResults) HigherIsBetter() bool {
+	return MetricHigherIsBetter(r.metric)
+}
+
+// Raw returns the flat distances and labels, including missing results.
+func (r *SearchResults) Raw() (distances []float32, labels []int64) {
+	return r.distances, r.labels
+}
+
+// Query returns the results of query i, best first, without the missing
+// results faiss pads the output with.
+func (r *SearchResults) Query(i int) []Neighbor {
+	lo, hi := int64(i)*r.k, int64(i+1)*r.k
+	rv := make([]Neighbor, 0, r.k)
+	for j := lo; j < hi; j++ {
+		if r.labels[j] < 0 {
+			continue
+		}
+		rv = append(rv, Neighbor{ID: r.labels[j], Distance: r.distances[j]})
+	}
+	return rv
+}
+
+// All iterates over the queries and their results, as returned by Query.
+func (r *SearchResults) All() iter.Seq2[int, []Neighbor] {
+	return func(yield func(int, []Neighbor) bool) {
+		for i := range r.Nq() {
+			if !yield(i, r.Query(i)) {
+				return
+			}
+		}
+	}
+}
+
+// BinarySearchResults is the binary index counterpart of SearchResults, with
+// Hamming distances.
+type BinarySearchResults struct {
+	k         int64
+	distances []int32
+	labels    []int64
+}
+
+// NewBinarySearchResults wraps the output of a k-nearest-neighbor search
+// performed on a binary index.
+func NewBinarySearchResults(distances []int32, labels []int64, k int64) (
+	*BinarySearchResults, error) {
+	if err := validateSearchResults(len(distances), len(labels), k); err != nil {
+		return nil, err
+	}
+	return &BinarySearchResults{k: k, distances: distances, labels: labels}, nil
+}
+
+// SearchBinaryNeighbors runs SearchWithOptions on idx and wraps the result.
+func SearchBinaryNeighbors(idx BinaryIndex, xb []uint8, k int64, sel Selector,
+	params json.RawMessage) (*BinarySearchResults, error) {
+	distances, labels, err := idx.SearchWithOptions(xb, k, sel, params)
+	if err != nil {
+		return nil, err
+	}
+	return NewBinarySearchResults(distances, labels, k)
+}
+
+// Nq returns the number of queries.
+func (r *BinarySearchResults) Nq() int {
+	return int(int64(len(r.labels)) / r.k)
+}
+
+// K returns the number of results requested per query.
+func (r *BinarySearchResults) K() int64 {
+	return r.k
+}
+
+// HigherIsBetter always returns false, as Hamming is a distance.
+func (r *BinarySearchResults) HigherIsBetter() bool {
+	return false
+}
+
+// Raw returns the flat distances and labels, including missing results.
+func (r *BinarySearchResults) Raw() (distances []int32, labels []int64) {
+	return r.distances, r.labels
+}
+
+// Query returns the results of query i, best first, without the missing
+// results faiss pads the output with.
+func (r *BinarySearchResults) Query(i int) []BinaryNeighbor {
+	lo, hi := int64(i)*r.k, int64(i+1)*r.k
+	rv := make([]BinaryNeighbor, 0, r.k)
+	for j := lo; j < hi; j++ {
+		if r.labels[j] < 0 {
+			continue
+		}
+		rv = append(rv, BinaryNeighbor{ID: r.labels[j], Distance: r.distances[j]})
+	}
+	return rv
+}
+
+// All iterates over the queries and their results, as returned by Query.
+func (r *BinarySearchResults) All() iter.Seq2[int, []BinaryNeighbor] {
+	return func(yield func(int, []BinaryNeighbor) bool) {
+		for i := range r.Nq() {
+			if !yield(i, r.Query(i)) {
+				return
+			}
+		}
+	}
+}
+
+func validateSearchResults(nDistances, nLabels int, k int64) error {
+	if err := validateK(k); err != nil {
+		return err
+	}
+	if nDistances != nLabels || int64(nLabels)%k != 0 {
+		return fmt.Errorf("%w: %d distances and %d labels for k=%d",
+			ErrDimensionMismatch, nDistances, nLabels, k)
+	}
+	return nil
+}
+
+// HigherIsBetter returns true if larger distances denote closer vectors, as
+// is the case for the inner product metric.
+func (r *RangeSearchResult) HigherIsBetter() bool {
+	return MetricHigherIsBetter(r.metric)
+}
+
+// Query returns the results of query i, copied out of C memory and sorted
+// best first according to the metric of the searched index, or nil if i is
+// not in [0, Nq()).
+func (r *RangeSearchResult) Query(i int) []Neighbor {
+	if r.rsr == nil || i < 0 || i >= r.Nq() {
+		return nil
+	}
+	defer runtime.KeepAlive(r)
+	lims := r.Lims()
+	labels, distances := r.Labels()
+	rv := make([]Neighbor, 0, lims[i+1]-lims[i])
+	for j := lims[i]; j < lims[i+1]; j++ {
+		rv = append(rv, Neighbor{ID: labels[j], Distance: distances[j]})
+	}
+	SortNeighbors(rv, r.HigherIsBetter())
+	return rv
+}
+
+// All iterates over the queries and their results, as returned by Query.
+func (r *RangeSearchResult) All() iter.Seq2[int, []Neighbor] {
+	return func(yield func(int, []Neighbor) bool) {
+		for i := range r.Nq() {
+			if !yield(i, r.Query(i)) {
+				return
+			}
+		}
+	}
+}
+
+// Query returns the results of query i, copied out of C memory and sorted
+// closest first.
+func (r *BinaryRangeSearchResult) Query(i int) []BinaryNeighbor {
+	if r.rsr.rsr == nil {
+		return nil
+	}
+	lims := r.Lims()
+	labels, fdistances := r.rsr.Labels()
+	rv := make([]BinaryNeighbor, 0, lims[i+1]-lims[i])
+	for j := lims[i]; j < lims[i+1]; j++ {
+		rv = append(rv, BinaryNeighbor{ID: labels[j], Distance: int32(fdistances[j])})
+	}
+	sort.Slice(rv, func(i, j int) bool {
+		if rv[i].Distance != rv[j].Distance {
+			return rv[i].Distance < rv[j].Distance
+		}
+		return rv[i].ID < rv[j].ID
+	})
+	return rv
+}
+
+// All iterates over the queries and their results, as returned by Query.
+func (r *BinaryRangeSearchResult) All() iter.Seq2[int, []BinaryNeighbor] {
+	return func(yield func(int, []BinaryNeighbor) bool) {
+		for i := range r.Nq() {
+			if !yield(i, r.Query(i)) {
+				return
+			}
+		}
+	}
+}
+
+// SortNeighbors sorts neighbors best first, breaking ties by ID.
+func SortNeighbors(neighbors []Neighbor, higherIsBetter bool) {
+	sort.Slice(neighbors, func(i, j int) bool {
+		a, b := neighbors[i], neighbors[j]
+		if a.Distance != b.Distance {
+			if higherIsBetter {
+				return a.Distance > b.Distance
+			}
+			return a.Distance < b.Distance
+		}
+		return a.ID < b.ID
+	})
+}
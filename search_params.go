@@ -6,23 +6,58 @@ package faiss
 #include <faiss/c_api/IndexIVF_c_ex.h>
 #include <faiss/c_api/IndexBinaryIVF_c.h>
 #include <faiss/c_api/impl/AuxIndexStructures_c.h>
+
+#include "gofaiss.h"
 */
 import "C"
 import (
 	"encoding/json"
 	"fmt"
+	"runtime"
 )
 
 type SearchParams struct {
 	sp *C.FaissSearchParameters
+
+	// selector is referenced by sp, so it is kept reachable for as long as
+	// the params are.
+	selector Selector
+
+	// cleanup frees sp if the params are garbage collected without Delete.
+	cleanup runtime.Cleanup
 }
 
-// Delete frees the memory associated with s.
+// track takes ownership of the freshly allocated s.sp.
+func (s *SearchParams) track() *SearchParams {
+	sp := s.sp
+	s.cleanup = addLeakCleanup(s, "faiss search params", func() {
+		C.faiss_SearchParameters_free(sp)
+	})
+	return s
+}
+
+// Delete frees the memory associated with s. It is safe to call Delete more
+// than once.
 func (s *SearchParams) Delete() {
 	if s == nil || s.sp == nil {
 		return
 	}
+	s.cleanup.Stop()
 	C.faiss_SearchParameters_free(s.sp)
+	s.sp = nil
+}
+
+// selectorPtr returns the C pointer of selector, which may be nil to indicate
+// no ID filtering.
+func selectorPtr(selector Selector) (*C.FaissIDSelector, error) {
+	if selector == nil {
+		return nil, nil
+	}
+	sel := selector.Get()
+	if sel == nil {
+		return nil, ErrSelectorClosed
+	}
+	return sel, nil
 }
 
 type searchParamsIVF struct {
@@ -61,28 +96,37 @@ func getNProbeFromSearchParams(params *SearchParams) int32 {
 // thus caller must clean up the object by invoking Delete() method.
 func NewSearchParams(idx Index, params json.RawMessage, selector Selector,
 	defaultParams *defaultSearchParamsIVF) (*SearchParams, error) {
+	idxPtr := idx.cPtr()
+	if idxPtr == nil {
+		return nil, ErrIndexClosed
+	}
+	defer runtime.KeepAlive(idx)
 	// Get the selector C pointer, if any.
 	// A nil selector indicates no ID filtering, and it is valid
 	// to send a nil pointer to Faiss.
-	var sel *C.FaissIDSelector
-	if selector != nil {
-		sel = selector.Get()
+	sel, err := selectorPtr(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	if hnswIdx := C.gofaiss_IndexHNSW_cast(idxPtr); hnswIdx != nil {
+		return buildHNSWSearchParams(params, selector, sel)
 	}
 
-	ivfIdx := C.faiss_IndexIVF_cast(idx.cPtr())
+	ivfIdx := C.faiss_IndexIVF_cast(idxPtr)
 	// if the index is not an IVF index, create a standard SearchParameters object
 	if ivfIdx == nil {
-		rv := &SearchParams{}
+		rv := &SearchParams{selector: selector}
 		// Create standard SearchParameters for non-IVF index
 		if c := C.faiss_SearchParameters_new(&rv.sp, sel); c != 0 {
 			return nil, ErrCreateParamsFailed
 		}
-		return rv, nil
+		return rv.track(), nil
 	}
 
 	nlist := int(C.faiss_IndexIVF_nlist(ivfIdx))
 	nprobe := int(C.faiss_IndexIVF_nprobe(ivfIdx))
-	nvecs := int(C.faiss_Index_ntotal(idx.cPtr()))
+	nvecs := int(C.faiss_Index_ntotal(idxPtr))
 
 	maxCodes, nprobe, err := resolveSearchParams(params, defaultParams, nlist, nprobe, nvecs)
 	if err != nil {
@@ -90,9 +134,9 @@ func NewSearchParams(idx Index, params json.RawMessage, selector Selector,
 	}
 
 	if idx.HasRaBitQ() {
-		return buildRaBitQSearchParams(maxCodes, nprobe, sel)
+		return buildRaBitQSearchParams(maxCodes, nprobe, selector, sel)
 	}
-	return buildIVFSearchParams(maxCodes, nprobe, sel)
+	return buildIVFSearchParams(maxCodes, nprobe, selector, sel)
 }
 
 func resolveSearchParams(params json.RawMessage, defaultParams *defaultSearchParamsIVF,
@@ -125,8 +169,54 @@ func resolveSearchParams(params json.RawMessage, defaultParams *defaultSearchPar
 	return maxCodes, nprobe, nil
 }
 
-func buildIVFSearchParams(maxCodes, nprobe int, sel *C.FaissIDSelector) (*SearchParams, error) {
-	sp := &SearchParams{}
+type searchParamsHNSW struct {
+	EfSearch int `json:"hnsw_ef_search,omitempty"`
+}
+
+func (s *searchParamsHNSW) Validate() error {
+	if s.EfSearch < 0 {
+		return fmt.Errorf("invalid HNSW search params, hnsw_ef_search:%v, "+
+			"should be positive", s.EfSearch)
+	}
+	return nil
+}
+
+// buildHNSWSearchParams overrides the efSearch of the index with the
+// hnsw_ef_search param, if set.
+func buildHNSWSearchParams(params json.RawMessage, selector Selector,
+	sel *C.FaissIDSelector) (*SearchParams, error) {
+	var hnswParams searchParamsHNSW
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &hnswParams); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal HNSW search params, "+
+				"err:%v", err)
+		}
+		if err := hnswParams.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	sp := &SearchParams{selector: selector}
+	if hnswParams.EfSearch == 0 {
+		// SearchParametersHNSW would override efSearch with its default.
+		if c := C.faiss_SearchParameters_new(&sp.sp, sel); c != 0 {
+			return nil, ErrCreateParamsFailed
+		}
+		return sp.track(), nil
+	}
+	if c := C.gofaiss_SearchParametersHNSW_new(
+		&sp.sp,
+		sel,
+		C.int(hnswParams.EfSearch),
+	); c != 0 {
+		return nil, ErrCreateParamsFailed
+	}
+	return sp.track(), nil
+}
+
+func buildIVFSearchParams(maxCodes, nprobe int, selector Selector,
+	sel *C.FaissIDSelector) (*SearchParams, error) {
+	sp := &SearchParams{selector: selector}
 	if c := C.faiss_SearchParametersIVF_new_with(
 		&sp.sp,
 		sel,
@@ -136,11 +226,12 @@ func buildIVFSearchParams(maxCodes, nprobe int, sel *C.FaissIDSelector) (*Search
 		return nil, ErrCreateParamsFailed
 	}
 
-	return sp, nil
+	return sp.track(), nil
 }
 
-func buildRaBitQSearchParams(maxCodes, nprobe int, sel *C.FaissIDSelector) (*SearchParams, error) {
-	sp := &SearchParams{}
+func buildRaBitQSearchParams(maxCodes, nprobe int, selector Selector,
+	sel *C.FaissIDSelector) (*SearchParams, error) {
+	sp := &SearchParams{selector: selector}
 	if c := C.faiss_SearchParametersRaBitQ_new_with(
 		&sp.sp,
 		sel,
@@ -150,54 +241,59 @@ func buildRaBitQSearchParams(maxCodes, nprobe int, sel *C.FaissIDSelector) (*Sea
 		return nil, ErrCreateParamsFailed
 	}
 
-	return sp, nil
+	return sp.track(), nil
 }
 
 // Returns a standard SearchParams object without any special settings with
 // the provided selector. The returned SearchParams object is allocated,
 // thus caller must clean up the object by invoking Delete() method.
 func NewStandardSearchParams(selector Selector) (*SearchParams, error) {
-	var sel *C.FaissIDSelector
-	if selector != nil {
-		sel = selector.Get()
+	sel, err := selectorPtr(selector)
+	if err != nil {
+		return nil, err
 	}
-	rv := &SearchParams{}
+	rv := &SearchParams{selector: selector}
 	if c := C.faiss_SearchParameters_new(&rv.sp, sel); c != 0 {
 		return nil, ErrCreateParamsFailed
 	}
-	return rv, nil
+	return rv.track(), nil
 }
 
 func NewBinarySearchParams(idx BinaryIndex, params json.RawMessage, selector Selector,
 	defaultParams *defaultSearchParamsIVF) (*SearchParams, error) {
+	idxPtr := idx.bPtr()
+	if idxPtr == nil {
+		return nil, ErrIndexClosed
+	}
+	defer runtime.KeepAlive(idx)
 	// Get the selector C pointer, if any.
 	// A nil selector indicates no ID filtering, and it is valid
 	// to send a nil pointer to Faiss.
-	var sel *C.FaissIDSelector
-	if selector != nil {
-		sel = selector.Get()
+	sel, err := selectorPtr(selector)
+	if err != nil {
+		return nil, err
 	}
 
-	ivfPtrBinary := C.faiss_IndexBinaryIVF_cast(idx.bPtr())
+	ivfPtrBinary := C.faiss_IndexBinaryIVF_cast(idxPtr)
 
 	// if the index is not an IVF index, create a standard SearchParameters object
 	if ivfPtrBinary == nil {
-		rv := &SearchParams{}
+		rv := &SearchParams{selector: selector}
 		// Create standard SearchParameters for non-IVF index
 		if c := C.faiss_SearchParameters_new(&rv.sp, sel); c != 0 {
 			return nil, ErrCreateParamsFailed
 		}
-		return rv, nil
+		return rv.track(), nil
 	}
 
 	nlist := int(C.faiss_IndexBinaryIVF_nlist(ivfPtrBinary))
 	nprobe := int(C.faiss_IndexBinaryIVF_nprobe(ivfPtrBinary))
-	nvecs := int(C.faiss_IndexBinary_ntotal(idx.bPtr()))
+	nvecs := int(C.faiss_IndexBinary_ntotal(idxPtr))
 
 	maxCodes, nprobe, err := resolveSearchParams(params, defaultParams, nlist, nprobe, nvecs)
 	if err != nil {
 		return nil, err
 	}
 
-	return buildIVFSearchParams(maxCodes, nprobe, sel)
+	return buildIVFSearchParams(maxCodes, nprobe, selector, sel)
 }
@@ -4,6 +4,7 @@ package faiss
 #include <faiss/c_api/impl/AuxIndexStructures_c.h>
 */
 import "C"
+import "runtime"
 
 // Note: currently we have only one implementation, but we keep the interface for future extensibility
 type Selector interface {
@@ -16,9 +17,50 @@ type Selector interface {
 type IDSelector struct {
 	exclude bool
 	sel     *C.FaissIDSelector
-	inner   *C.FaissIDSelector
+	// C selectors referenced by sel which were created along with it, and
+	// are freed along with it.
+	inner []*C.FaissIDSelector
+	// selectors sel is composed of, which are owned by this selector and
+	// deleted along with it.
+	children []Selector
+	// release frees the other resources sel references, if any, such as C
+	// memory or the handle of a Go predicate.
+	release func()
+	// predicateErr returns and clears the recorded panic of the Go predicate
+	// of a callback selector, see NewIDSelectorFunc.
+	predicateErr func() error
+
+	// cleanup frees sel and inner and calls release if the selector is
+	// garbage collected without Delete.
+	cleanup runtime.Cleanup
+}
+
+// newIDSelector wraps a selector allocated on the C heap, taking ownership of
+// it and of the inner selectors it references.
+func newIDSelector(sel *C.FaissIDSelector, exclude bool,
+	inner ...*C.FaissIDSelector) *IDSelector {
+	return newIDSelectorWithRelease(sel, exclude, nil, inner...)
+}
+
+// newIDSelectorWithRelease is like newIDSelector, and additionally calls
+// release, if not nil, when the selector is freed.
+func newIDSelectorWithRelease(sel *C.FaissIDSelector, exclude bool,
+	release func(), inner ...*C.FaissIDSelector) *IDSelector {
+	s := &IDSelector{exclude: exclude, sel: sel, inner: inner, release: release}
+	s.cleanup = addLeakCleanup(s, "faiss ID selector", func() {
+		C.faiss_IDSelector_free(sel)
+		for _, in := range inner {
+			C.faiss_IDSelector_free(in)
+		}
+		if release != nil {
+			release()
+		}
+	})
+	return s
 }
 
+// Get returns the underlying C selector, or nil once the selector has been
+// deleted.
 func (s *IDSelector) Get() *C.FaissIDSelector {
 	return s.sel
 }
@@ -27,18 +69,55 @@ func (s *IDSelector) ExcludeFilter() bool {
 	return s.exclude
 }
 
-// Delete frees the memory associated with s.
-func (s *IDSelector) Delete() {
+// predicateError returns and clears the first panic of the Go predicates of
+// s and of the selectors it is composed of, recorded since the last call.
+func (s *IDSelector) predicateError() error {
 	if s == nil {
+		return nil
+	}
+	var err error
+	if s.predicateErr != nil {
+		err = s.predicateErr()
+	}
+	for _, child := range s.children {
+		if childErr := selectorError(child); err == nil {
+			err = childErr
+		}
+	}
+	return err
+}
+
+// selectorError returns the error of the call sel was passed to if one of
+// its Go predicates panicked during the call, see NewIDSelectorFunc.
+func selectorError(sel Selector) error {
+	if s, ok := sel.(interface{ predicateError() error }); ok {
+		return s.predicateError()
+	}
+	return nil
+}
+
+// Delete frees the memory associated with s. It is safe to call Delete more
+// than once.
+func (s *IDSelector) Delete() {
+	if s == nil || s.sel == nil {
 		return
 	}
 
-	if s.sel != nil {
-		C.faiss_IDSelector_free(s.sel)
+	s.cleanup.Stop()
+	C.faiss_IDSelector_free(s.sel)
+	for _, in := range s.inner {
+		C.faiss_IDSelector_free(in)
 	}
-	if s.inner != nil {
-		C.faiss_IDSelector_free(s.inner)
+	for _, child := range s.children {
+		child.Delete()
 	}
+	if s.release != nil {
+		s.release()
+	}
+	s.sel = nil
+	s.inner = nil
+	s.children = nil
+	s.release = nil
 }
 
 // NewIDSelectorRange creates a selector that removes IDs on [imin, imax).
@@ -48,7 +127,7 @@ func NewIDSelectorRange(imin, imax int64) (Selector, error) {
 	if c != 0 {
 		return nil, newFaissError(ErrCreateSelectorFailed, getLastError(), int(c))
 	}
-	return &IDSelector{sel: (*C.FaissIDSelector)(sel)}, nil
+	return newIDSelector((*C.FaissIDSelector)(sel), false), nil
 }
 
 // NewIDSelectorBatch creates a new batch selector.
@@ -61,28 +140,31 @@ func NewIDSelectorBatch(indices []int64) (Selector, error) {
 	); c != 0 {
 		return nil, newFaissError(ErrCreateSelectorFailed, getLastError(), int(c))
 	}
-	return &IDSelector{sel: (*C.FaissIDSelector)(sel)}, nil
+	return newIDSelector((*C.FaissIDSelector)(sel), false), nil
 }
 
 // NewIDSelectorBatchNot creates a new Not selector, wrapped around a
 // batch selector, with the IDs in 'exclude'.
 func NewIDSelectorBatchNot(exclude []int64) (Selector, error) {
-	batchSelector, err := NewIDSelectorBatch(exclude)
-	if err != nil {
-		return nil, err
+	var batchSel *C.FaissIDSelectorBatch
+	if c := C.faiss_IDSelectorBatch_new(
+		&batchSel,
+		C.size_t(len(exclude)),
+		(*C.idx_t)(&exclude[0]),
+	); c != 0 {
+		return nil, newFaissError(ErrCreateSelectorFailed, getLastError(), int(c))
 	}
 
 	var sel *C.FaissIDSelectorNot
 	if c := C.faiss_IDSelectorNot_new(
 		&sel,
-		batchSelector.Get(),
+		(*C.FaissIDSelector)(batchSel),
 	); c != 0 {
-		batchSelector.Delete()
+		C.faiss_IDSelector_free((*C.FaissIDSelector)(batchSel))
 		return nil, newFaissError(ErrCreateSelectorFailed, getLastError(), int(c))
 	}
-	return &IDSelector{exclude: true,
-		sel:   (*C.FaissIDSelector)(sel),
-		inner: batchSelector.Get()}, nil
+	return newIDSelector((*C.FaissIDSelector)(sel), true,
+		(*C.FaissIDSelector)(batchSel)), nil
 }
 
 // NewIDSelectorBitmap creates a selector using a bitset, where each bit
@@ -100,7 +182,7 @@ func NewIDSelectorBitmap(bitmap []byte) (Selector, error) {
 	); c != 0 {
 		return nil, newFaissError(ErrCreateSelectorFailed, getLastError(), int(c))
 	}
-	return &IDSelector{sel: (*C.FaissIDSelector)(sel)}, nil
+	return newIDSelector((*C.FaissIDSelector)(sel), false), nil
 }
 
 // NewIDSelectorBitmapNot creates a NOT selector using a bitset, where each bit
@@ -110,19 +192,109 @@ func NewIDSelectorBitmap(bitmap []byte) (Selector, error) {
 // where N is the number of vectors in the index.
 // The length of the bitmap should be at least ceil(N/8).
 func NewIDSelectorBitmapNot(bitmap []byte) (Selector, error) {
-	bitmapSelector, err := NewIDSelectorBitmap(bitmap)
-	if err != nil {
-		return nil, err
+	var bitmapSel *C.FaissIDSelectorBitmap
+	if c := C.faiss_IDSelectorBitmap_new(
+		&bitmapSel,
+		C.size_t(len(bitmap)),
+		(*C.uint8_t)(&bitmap[0]),
+	); c != 0 {
+		return nil, newFaissError(ErrCreateSelectorFailed, getLastError(), int(c))
 	}
 	var sel *C.FaissIDSelectorNot
 	if c := C.faiss_IDSelectorNot_new(
 		&sel,
-		bitmapSelector.Get(),
+		(*C.FaissIDSelector)(bitmapSel),
 	); c != 0 {
-		bitmapSelector.Delete()
+		C.faiss_IDSelector_free((*C.FaissIDSelector)(bitmapSel))
+		return nil, newFaissError(ErrCreateSelectorFailed, getLastError(), int(c))
+	}
+	return newIDSelector((*C.FaissIDSelector)(sel), true,
+		(*C.FaissIDSelector)(bitmapSel)), nil
+}
+
+// selectorOperands returns the C selectors of the given operands of a
+// composite selector.
+func selectorOperands(operands ...Selector) ([]*C.FaissIDSelector, error) {
+	rv := make([]*C.FaissIDSelector, len(operands))
+	for i, operand := range operands {
+		if operand == nil {
+			return nil, ErrSelectorNil
+		}
+		if rv[i] = operand.Get(); rv[i] == nil {
+			return nil, ErrSelectorClosed
+		}
+	}
+	return rv, nil
+}
+
+// NewIDSelectorNot creates a selector which selects the IDs s does not
+// select.
+// The returned selector takes ownership of s: deleting it deletes s as well,
+// so s must not be deleted or used in another composite selector.
+func NewIDSelectorNot(s Selector) (Selector, error) {
+	operands, err := selectorOperands(s)
+	if err != nil {
+		return nil, err
+	}
+	var sel *C.FaissIDSelectorNot
+	if c := C.faiss_IDSelectorNot_new(&sel, operands[0]); c != 0 {
+		return nil, newFaissError(ErrCreateSelectorFailed, getLastError(), int(c))
+	}
+	rv := newIDSelector((*C.FaissIDSelector)(sel), !s.ExcludeFilter())
+	rv.children = []Selector{s}
+	return rv, nil
+}
+
+// NewIDSelectorAnd creates a selector which selects the IDs selected by both
+// a and b.
+// The returned selector takes ownership of a and b: deleting it deletes them
+// as well, so they must not be deleted or used in another composite selector.
+func NewIDSelectorAnd(a, b Selector) (Selector, error) {
+	operands, err := selectorOperands(a, b)
+	if err != nil {
+		return nil, err
+	}
+	var sel *C.FaissIDSelectorAnd
+	if c := C.faiss_IDSelectorAnd_new(&sel, operands[0], operands[1]); c != 0 {
+		return nil, newFaissError(ErrCreateSelectorFailed, getLastError(), int(c))
+	}
+	rv := newIDSelector((*C.FaissIDSelector)(sel), false)
+	rv.children = []Selector{a, b}
+	return rv, nil
+}
+
+// NewIDSelectorOr creates a selector which selects the IDs selected by a, b
+// or both.
+// The returned selector takes ownership of a and b: deleting it deletes them
+// as well, so they must not be deleted or used in another composite selector.
+func NewIDSelectorOr(a, b Selector) (Selector, error) {
+	operands, err := selectorOperands(a, b)
+	if err != nil {
+		return nil, err
+	}
+	var sel *C.FaissIDSelectorOr
+	if c := C.faiss_IDSelectorOr_new(&sel, operands[0], operands[1]); c != 0 {
+		return nil, newFaissError(ErrCreateSelectorFailed, getLastError(), int(c))
+	}
+	rv := newIDSelector((*C.FaissIDSelector)(sel), false)
+	rv.children = []Selector{a, b}
+	return rv, nil
+}
+
+// NewIDSelectorXOr creates a selector which selects the IDs selected by
+// exactly one of a and b.
+// The returned selector takes ownership of a and b: deleting it deletes them
+// as well, so they must not be deleted or used in another composite selector.
+func NewIDSelectorXOr(a, b Selector) (Selector, error) {
+	operands, err := selectorOperands(a, b)
+	if err != nil {
+		return nil, err
+	}
+	var sel *C.FaissIDSelectorXOr
+	if c := C.faiss_IDSelectorXOr_new(&sel, operands[0], operands[1]); c != 0 {
 		return nil, newFaissError(ErrCreateSelectorFailed, getLastError(), int(c))
 	}
-	return &IDSelector{exclude: true,
-		sel:   (*C.FaissIDSelector)(sel),
-		inner: bitmapSelector.Get()}, nil
+	rv := newIDSelector((*C.FaissIDSelector)(sel), false)
+	rv.children = []Selector{a, b}
+	return rv, nil
 }
@@ -0,0 +1,129 @@
+// Selectors whose membership test is a Go function, see selector_func.go.
+
+#include "gofaiss.h"
+#include "gofaiss_impl.h"
+
+#include <mutex>
+#include <shared_mutex>
+#include <unordered_map>
+#include <vector>
+
+#include <faiss/impl/IDSelector.h>
+
+namespace {
+
+// Calls back into Go for every ID.
+struct IDSelectorFunc : faiss::IDSelector {
+    uintptr_t handle;
+
+    explicit IDSelectorFunc(uintptr_t handle) : handle(handle) {}
+
+    bool is_member(faiss::idx_t id) const override {
+        return goFaissIDSelectorIsMember(handle, id) != 0;
+    }
+};
+
+// Calls back into Go once per chunk of chunk_size consecutive IDs, the first
+// time an ID of the chunk is asked about, and caches the memberships of the
+// whole chunk, evicting an arbitrary chunk once max_chunks are cached.
+// is_member is called concurrently by the OpenMP threads of a search, hence
+// the lock.
+struct IDSelectorFuncBatched : faiss::IDSelector {
+    uintptr_t handle;
+    size_t chunk_size;
+    size_t max_chunks;
+
+    mutable std::shared_mutex mutex;
+    mutable std::unordered_map<faiss::idx_t, std::vector<uint8_t>> chunks;
+
+    IDSelectorFuncBatched(
+            uintptr_t handle,
+            size_t chunk_size,
+            size_t max_chunks)
+            : handle(handle), chunk_size(chunk_size), max_chunks(max_chunks) {}
+
+    bool is_member(faiss::idx_t id) const override {
+        if (id < 0) {
+            return false;
+        }
+        faiss::idx_t chunk = id / chunk_size;
+        size_t offset = id % chunk_size;
+        {
+            std::shared_lock<std::shared_mutex> lock(mutex);
+            auto it = chunks.find(chunk);
+            if (it != chunks.end()) {
+                return it->second[offset] != 0;
+            }
+        }
+
+        // Evaluated without holding the lock, so two threads may evaluate the
+        // same chunk, in which case the first result is kept.
+        std::vector<uint8_t> members(chunk_size);
+        bool panicked = goFaissIDSelectorFillChunk(
+                                handle,
+                                chunk * chunk_size,
+                                chunk_size,
+                                members.data()) != 0;
+        bool rv = members[offset] != 0;
+        // the chunk is evaluated again next time rather than cached with
+        // the IDs the predicate panicked on deselected.
+        if (panicked) {
+            return rv;
+        }
+
+        std::unique_lock<std::shared_mutex> lock(mutex);
+        if (chunks.size() >= max_chunks && chunks.count(chunk) == 0) {
+            chunks.erase(chunks.begin());
+        }
+        chunks.emplace(chunk, std::move(members));
+        return rv;
+    }
+
+    void reset() {
+        std::unique_lock<std::shared_mutex> lock(mutex);
+        chunks.clear();
+    }
+};
+
+} // namespace
+
+int gofaiss_IDSelectorFunc_new(FaissIDSelector** p_sel, uintptr_t handle) {
+    GOFAISS_TRY
+    *p_sel = reinterpret_cast<FaissIDSelector*>(new IDSelectorFunc(handle));
+    GOFAISS_CATCH
+}
+
+int gofaiss_IDSelectorFuncBatched_new(
+        FaissIDSelector** p_sel,
+        uintptr_t handle,
+        size_t chunk_size,
+        size_t max_chunks) {
+    GOFAISS_TRY
+    *p_sel = reinterpret_cast<FaissIDSelector*>(
+            new IDSelectorFuncBatched(handle, chunk_size, max_chunks));
+    GOFAISS_CATCH
+}
+
+void gofaiss_IDSelectorFuncBatched_reset(FaissIDSelector* sel) {
+    auto batched = dynamic_cast<IDSelectorFuncBatched*>(
+            reinterpret_cast<faiss::IDSelector*>(sel));
+    if (batched != nullptr) {
+        batched->reset();
+    }
+}
+
+int gofaiss_IDSelector_count_members(
+        const FaissIDSelector* sel,
+        int64_t n,
+        int64_t* p_count) {
+    GOFAISS_TRY
+    auto s = reinterpret_cast<const faiss::IDSelector*>(sel);
+    int64_t count = 0;
+    for (faiss::idx_t id = 0; id < n; id++) {
+        if (s->is_member(id)) {
+            count++;
+        }
+    }
+    *p_count = count;
+    GOFAISS_CATCH
+}
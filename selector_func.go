@@ -0,0 +1,202 @@
+package faiss
+
+/*
+#include "gofaiss.h"
+*/
+import "C"
+import (
+	"fmt"
+	"runtime"
+	"runtime/cgo"
+	"sync"
+	"unsafe"
+)
+
+// Selectors created by NewIDSelectorFunc and NewIDSelectorFuncBatched call
+// back into Go from the threads faiss searches with. faiss evaluates the
+// selector once per candidate, i.e. for every vector of a flat index and for
+// every vector of the nprobe probed lists of an IVF index, per query.
+//
+// Cost per ID measured by BenchmarkIDSelectorMembership, which evaluates each
+// selector over 1M consecutive IDs from C, on one core of a Xeon VM.
+// BenchmarkIDSelectorFunc and the other search benchmarks next to it measure
+// the cost per candidate within the search of a flat index instead.
+//
+//	IDSelectorBitmap (C)                      ~3 ns
+//	IDSelectorBatch (C)                       ~3 ns
+//	NewIDSelectorFuncBatched, cached chunks  ~30 ns
+//	NewIDSelectorFuncBatched, after Reset    ~37 ns
+//	NewIDSelectorFunc                       ~115 ns
+//
+//   - NewIDSelectorFunc crosses into Go once for every candidate, which
+//     dominates the search time on small vectors, in particular on flat
+//     indexes.
+//   - NewIDSelectorFuncBatched crosses into Go once per chunk of IDs, and then
+//     answers from a cache until it is reset, at the cost of a read lock and a
+//     map lookup per candidate, which make up most of its cost, and of one
+//     byte per cached ID, up to selectorCacheMaxIDs. The predicate may be
+//     called for IDs which are not in the index.
+//
+// Selectors built from IDs known in advance (NewIDSelectorBatch,
+// NewIDSelectorBitmap) are evaluated entirely in C and remain the fastest
+// option: use the callback selectors for predicates over data which only
+// lives in Go, and prefer the batched variant unless few candidates are
+// evaluated per search, e.g. with IVF indexes and a low nprobe on a large
+// ID range.
+
+// DefaultSelectorChunkSize is the default number of IDs evaluated per call of
+// the predicate of a NewIDSelectorFuncBatched selector.
+const DefaultSelectorChunkSize = 1024
+
+// selectorCacheMaxIDs bounds the number of IDs whose membership a
+// NewIDSelectorFuncBatched selector caches, i.e. its cache to 1 MiB. Chunks
+// are evicted beyond that and evaluated again when needed.
+const selectorCacheMaxIDs = 1 << 20
+
+// NewIDSelectorFunc creates a selector which selects the IDs for which
+// predicate returns true.
+// predicate is called concurrently from the search threads and must be safe
+// for concurrent use. A panic in predicate deselects the ID, and makes the
+// search, or any other call the selector is passed to, return an error
+// wrapping ErrSelectorPanicked once faiss is done. If the selector is used
+// by concurrent calls, any one of them may return the error.
+// See the cost model above, NewIDSelectorFuncBatched is usually faster.
+func NewIDSelectorFunc(predicate func(id int64) bool) (Selector, error) {
+	if predicate == nil {
+		return nil, fmt.Errorf("%w: nil predicate", ErrCreateSelectorFailed)
+	}
+	p := &predicateSelector{predicate: predicate}
+	h := cgo.NewHandle(p)
+	var sel *C.FaissIDSelector
+	if c := C.gofaiss_IDSelectorFunc_new(&sel, C.uintptr_t(h)); c != 0 {
+		h.Delete()
+		return nil, newFaissError(ErrCreateSelectorFailed, getLastError(), int(c))
+	}
+	rv := newIDSelectorWithRelease(sel, false, h.Delete)
+	rv.predicateErr = p.takePanic
+	return rv, nil
+}
+
+// IDSelectorFuncBatched is a selector created by NewIDSelectorFuncBatched,
+// which caches the results of its predicate.
+type IDSelectorFuncBatched struct {
+	*IDSelector
+}
+
+// NewIDSelectorFuncBatched is like NewIDSelectorFunc, but evaluates predicate
+// on chunks of chunkSize consecutive IDs at once and caches the results, so
+// that a single transition from C to Go covers up to chunkSize candidates.
+// A chunkSize of 0 selects DefaultSelectorChunkSize.
+// As the results are cached, possibly across searches, call Reset whenever
+// the data predicate depends on changes, e.g. before each search if it
+// filters on permissions or timestamps which may change in between.
+func NewIDSelectorFuncBatched(predicate func(id int64) bool, chunkSize int) (
+	*IDSelectorFuncBatched, error) {
+	if predicate == nil {
+		return nil, fmt.Errorf("%w: nil predicate", ErrCreateSelectorFailed)
+	}
+	if chunkSize < 0 {
+		return nil, fmt.Errorf("%w: chunk size %d", ErrCreateSelectorFailed, chunkSize)
+	}
+	if chunkSize == 0 {
+		chunkSize = DefaultSelectorChunkSize
+	}
+	maxChunks := max(selectorCacheMaxIDs/chunkSize, 1)
+	p := &predicateSelector{predicate: predicate}
+	h := cgo.NewHandle(p)
+	var sel *C.FaissIDSelector
+	if c := C.gofaiss_IDSelectorFuncBatched_new(&sel, C.uintptr_t(h),
+		C.size_t(chunkSize), C.size_t(maxChunks)); c != 0 {
+		h.Delete()
+		return nil, newFaissError(ErrCreateSelectorFailed, getLastError(), int(c))
+	}
+	rv := newIDSelectorWithRelease(sel, false, h.Delete)
+	rv.predicateErr = p.takePanic
+	return &IDSelectorFuncBatched{rv}, nil
+}
+
+// Reset drops the cached results, so that the predicate is evaluated again.
+// It may be called concurrently with searches, which then see either the old
+// or the new results for each chunk of IDs.
+func (s *IDSelectorFuncBatched) Reset() {
+	if sel := s.Get(); sel != nil {
+		C.gofaiss_IDSelectorFuncBatched_reset(sel)
+	}
+}
+
+// countMembers returns the number of IDs in [0, n) sel selects, evaluating
+// sel once per ID from C, without a search. Used by the selector benchmarks.
+func countMembers(sel Selector, n int64) (int64, error) {
+	defer runtime.KeepAlive(sel)
+	var count C.int64_t
+	c := C.gofaiss_IDSelector_count_members(sel.Get(), C.int64_t(n), &count)
+	if err := selectorError(sel); err != nil {
+		return 0, err
+	}
+	if c != 0 {
+		return 0, newFaissError(ErrSearchFailed, getLastError(), int(c))
+	}
+	return int64(count), nil
+}
+
+// predicateSelector is the Go side of a callback selector, which the C
+// selector references through a cgo.Handle.
+type predicateSelector struct {
+	predicate func(id int64) bool
+
+	mu sync.Mutex
+	// first panic of predicate not yet reported by takePanic.
+	panicErr error
+}
+
+// call calls predicate, recovering from a panic, which must not unwind
+// through the C++ frames of faiss, and is recorded for takePanic instead.
+func (p *predicateSelector) call(id int64) (rv, panicked bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			p.mu.Lock()
+			if p.panicErr == nil {
+				p.panicErr = fmt.Errorf("%w on ID %d: %v", ErrSelectorPanicked, id, r)
+			}
+			p.mu.Unlock()
+			rv, panicked = false, true
+		}
+	}()
+	return p.predicate(id), false
+}
+
+// takePanic returns and clears the recorded panic of predicate, if any.
+func (p *predicateSelector) takePanic() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	err := p.panicErr
+	p.panicErr = nil
+	return err
+}
+
+//export goFaissIDSelectorIsMember
+func goFaissIDSelectorIsMember(handle C.uintptr_t, id C.int64_t) C.int {
+	p := cgo.Handle(handle).Value().(*predicateSelector)
+	if rv, _ := p.call(int64(id)); rv {
+		return 1
+	}
+	return 0
+}
+
+//export goFaissIDSelectorFillChunk
+func goFaissIDSelectorFillChunk(handle C.uintptr_t, start C.int64_t, n C.size_t,
+	members *C.uint8_t) C.int {
+	p := cgo.Handle(handle).Value().(*predicateSelector)
+	out := unsafe.Slice((*uint8)(unsafe.Pointer(members)), int(n))
+	var rv C.int
+	for i := range out {
+		out[i] = 0
+		selected, panicked := p.call(int64(start) + int64(i))
+		if panicked {
+			rv = 1
+		} else if selected {
+			out[i] = 1
+		}
+	}
+	return rv
+}
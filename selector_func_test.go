@@ -0,0 +1,174 @@
+package faiss
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// Sizes of the flat index searched by the selector benchmarks, which
+// evaluates the selector once per indexed vector and query.
+const (
+	benchSelectorNtotal = 1 << 16
+	benchSelectorD      = 16
+)
+
+func isEvenID(id int64) bool {
+	return id%2 == 0
+}
+
+// benchmarkSelector searches a flat index with the selector returned by
+// newSelector, which selects the even IDs, and reports the cost per
+// evaluated candidate.
+func benchmarkSelector(b *testing.B, newSelector func() (Selector, error)) {
+	idx, err := NewIndexFlatL2(benchSelectorD)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer idx.Close()
+	rng := rand.New(rand.NewSource(0))
+	xb := make([]float32, benchSelectorNtotal*benchSelectorD)
+	for i := range xb {
+		xb[i] = rng.Float32()
+	}
+	if err := idx.Add(xb); err != nil {
+		b.Fatal(err)
+	}
+	sel, err := newSelector()
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer sel.Delete()
+	query := xb[:benchSelectorD]
+
+	b.ResetTimer()
+	for range b.N {
+		_, labels, err := idx.SearchWithOptions(query, 10, sel, nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if labels[0] != 0 {
+			b.Fatalf("nearest neighbor is %d, want 0", labels[0])
+		}
+	}
+	b.ReportMetric(float64(b.Elapsed().Nanoseconds())/
+		float64(b.N)/benchSelectorNtotal, "ns/candidate")
+}
+
+func BenchmarkIDSelectorFunc(b *testing.B) {
+	benchmarkSelector(b, func() (Selector, error) {
+		return NewIDSelectorFunc(isEvenID)
+	})
+}
+
+func BenchmarkIDSelectorFuncBatched(b *testing.B) {
+	benchmarkSelector(b, func() (Selector, error) {
+		return NewIDSelectorFuncBatched(isEvenID, 0)
+	})
+}
+
+// Baselines evaluated entirely in C.
+
+func BenchmarkIDSelectorBatch(b *testing.B) {
+	benchmarkSelector(b, func() (Selector, error) {
+		ids := make([]int64, 0, benchSelectorNtotal/2)
+		for id := int64(0); id < benchSelectorNtotal; id += 2 {
+			ids = append(ids, id)
+		}
+		return NewIDSelectorBatch(ids)
+	})
+}
+
+func BenchmarkIDSelectorBitmap(b *testing.B) {
+	benchmarkSelector(b, func() (Selector, error) {
+		bitmap := make([]byte, benchSelectorNtotal/8)
+		for i := range bitmap {
+			bitmap[i] = 0x55
+		}
+		return NewIDSelectorBitmap(bitmap)
+	})
+}
+
+// benchSelectorMembershipIDs is the number of consecutive IDs
+// BenchmarkIDSelectorMembership evaluates the selectors over.
+const benchSelectorMembershipIDs = 1 << 20
+
+// BenchmarkIDSelectorMembership measures the cost of the selectors alone,
+// evaluating each over benchSelectorMembershipIDs consecutive IDs from C,
+// which is the method of the cost table in selector_func.go.
+func BenchmarkIDSelectorMembership(b *testing.B) {
+	const n = benchSelectorMembershipIDs
+	batched := func(reset bool) func(b *testing.B) {
+		return func(b *testing.B) {
+			sel, err := NewIDSelectorFuncBatched(isEvenID, 0)
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer sel.Delete()
+			// fill the cache, so that without reset every iteration
+			// answers from it.
+			if _, err := countMembers(sel, n); err != nil {
+				b.Fatal(err)
+			}
+			benchmarkMembership(b, sel, func() {
+				if reset {
+					sel.Reset()
+				}
+			})
+		}
+	}
+	b.Run("Func", func(b *testing.B) {
+		sel, err := NewIDSelectorFunc(isEvenID)
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer sel.Delete()
+		benchmarkMembership(b, sel, nil)
+	})
+	b.Run("FuncBatched", batched(false))
+	b.Run("FuncBatchedReset", batched(true))
+	b.Run("Batch", func(b *testing.B) {
+		ids := make([]int64, 0, n/2)
+		for id := int64(0); id < n; id += 2 {
+			ids = append(ids, id)
+		}
+		sel, err := NewIDSelectorBatch(ids)
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer sel.Delete()
+		benchmarkMembership(b, sel, nil)
+	})
+	b.Run("Bitmap", func(b *testing.B) {
+		bitmap := make([]byte, n/8)
+		for i := range bitmap {
+			bitmap[i] = 0x55
+		}
+		sel, err := NewIDSelectorBitmap(bitmap)
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer sel.Delete()
+		benchmarkMembership(b, sel, nil)
+	})
+}
+
+// benchmarkMembership counts the IDs sel selects among the first
+// benchSelectorMembershipIDs, calling before, if not nil, ahead of every
+// count, and reports the cost per ID.
+func benchmarkMembership(b *testing.B, sel Selector, before func()) {
+	const n = benchSelectorMembershipIDs
+	b.ResetTimer()
+	for range b.N {
+		if before != nil {
+			before()
+		}
+		count, err := countMembers(sel, n)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if count != n/2 {
+			b.Fatalf("selected %d IDs, want %d", count, n/2)
+		}
+	}
+	b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N)/n, "ns/ID")
+}
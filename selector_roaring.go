@@ -0,0 +1,122 @@
+package faiss
+
+/*
+#include <stdlib.h>
+
+#include <faiss/c_api/impl/AuxIndexStructures_c.h>
+*/
+import "C"
+import (
+	"fmt"
+	"unsafe"
+)
+
+// RoaringBitmap is the part of the API of *roaring.Bitmap
+// (github.com/RoaringBitmap/roaring) NewIDSelectorFromRoaring relies on, so that
+// bitmaps can be passed as is without this package depending on roaring.
+type RoaringBitmap interface {
+	GetCardinality() uint64
+	// Maximum returns the largest ID of a non-empty bitmap.
+	Maximum() uint32
+	// Iterate calls cb for each ID in increasing order, until cb returns
+	// false.
+	Iterate(cb func(x uint32) bool)
+}
+
+// roaringDenseRatio is the density, in IDs per selected ID, under which a
+// roaring bitmap is converted to a batch selector rather than a dense bitmap.
+// A batch selector costs a few tens of bytes per selected ID where a dense
+// bitmap costs one bit per ID of the ID range, and the bitmap has the cheaper
+// membership test.
+const roaringDenseRatio = 256
+
+// IndexNtotal is implemented by both Index and BinaryIndex.
+type IndexNtotal interface {
+	Ntotal() int64
+}
+
+// NewIDSelectorFromRoaring converts bm into a selector which selects the IDs
+// in bm, for searching idx.
+// The IDs are copied, depending on the cardinality of bm relative to its ID
+// range, which is at least idx.Ntotal(), either into a dense bitmap or into a
+// batch selector. The selector therefore does not reference bm once created,
+// and does not see later changes to bm: convert bm again after changing it.
+func NewIDSelectorFromRoaring(idx IndexNtotal, bm RoaringBitmap) (Selector, error) {
+	return newIDSelectorFromRoaring(idx, bm, false)
+}
+
+// NewIDSelectorFromRoaringNot converts bm into a selector which selects the
+// IDs not in bm, for searching idx, see NewIDSelectorFromRoaring.
+func NewIDSelectorFromRoaringNot(idx IndexNtotal, bm RoaringBitmap) (Selector, error) {
+	return newIDSelectorFromRoaring(idx, bm, true)
+}
+
+func newIDSelectorFromRoaring(idx IndexNtotal, bm RoaringBitmap, not bool) (Selector, error) {
+	if idx == nil {
+		return nil, ErrIndexNil
+	}
+	if bm == nil {
+		return nil, fmt.Errorf("%w: nil roaring bitmap", ErrCreateSelectorFailed)
+	}
+	cardinality := bm.GetCardinality()
+	// the dense bitmap only needs to cover the IDs up to the largest one in
+	// bm, IDs past its end are not selected. An empty bitmap selects nothing,
+	// which a single byte bitmap expresses.
+	bitmapLen := uint64(1)
+	if cardinality > 0 {
+		bitmapLen = (uint64(bm.Maximum()) + 8) / 8
+	}
+	idRange := max(uint64(max(idx.Ntotal(), 0)), bitmapLen*8)
+
+	var sel *C.FaissIDSelector
+	var release func()
+	if cardinality == 0 || cardinality*roaringDenseRatio >= idRange {
+		// faiss keeps a pointer to the bitmap, which therefore has to live on
+		// the C heap, and be freed along with the selector.
+		n := bitmapLen
+		bitmap := C.calloc(C.size_t(n), 1)
+		if bitmap == nil {
+			return nil, fmt.Errorf("%w: failed to allocate %d bytes",
+				ErrCreateSelectorFailed, n)
+		}
+		bits := unsafe.Slice((*uint8)(bitmap), n)
+		bm.Iterate(func(id uint32) bool {
+			bits[id>>3] |= 1 << (id & 7)
+			return true
+		})
+		var bitmapSel *C.FaissIDSelectorBitmap
+		if c := C.faiss_IDSelectorBitmap_new(&bitmapSel, C.size_t(n),
+			(*C.uint8_t)(bitmap)); c != 0 {
+			C.free(bitmap)
+			return nil, newFaissError(ErrCreateSelectorFailed, getLastError(), int(c))
+		}
+		sel = (*C.FaissIDSelector)(bitmapSel)
+		release = func() { C.free(bitmap) }
+	} else {
+		// the batch selector copies the IDs.
+		ids := make([]int64, 0, cardinality)
+		bm.Iterate(func(id uint32) bool {
+			ids = append(ids, int64(id))
+			return true
+		})
+		var batchSel *C.FaissIDSelectorBatch
+		if c := C.faiss_IDSelectorBatch_new(&batchSel, C.size_t(len(ids)),
+			(*C.idx_t)(&ids[0])); c != 0 {
+			return nil, newFaissError(ErrCreateSelectorFailed, getLastError(), int(c))
+		}
+		sel = (*C.FaissIDSelector)(batchSel)
+	}
+
+	if !not {
+		return newIDSelectorWithRelease(sel, false, release), nil
+	}
+	var notSel *C.FaissIDSelectorNot
+	if c := C.faiss_IDSelectorNot_new(&notSel, sel); c != 0 {
+		C.faiss_IDSelector_free(sel)
+		if release != nil {
+			release()
+		}
+		return nil, newFaissError(ErrCreateSelectorFailed, getLastError(), int(c))
+	}
+	return newIDSelectorWithRelease((*C.FaissIDSelector)(notSel), true, release, sel), nil
+}
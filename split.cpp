@@ -0,0 +1,176 @@
+// Splitting of IVF indexes into shards, see split.go.
+
+#include "gofaiss.h"
+#include "gofaiss_impl.h"
+
+#include <algorithm>
+#include <memory>
+#include <type_traits>
+#include <vector>
+
+#include <faiss/IndexBinaryIVF.h>
+#include <faiss/IndexIVF.h>
+#include <faiss/clone_index.h>
+#include <faiss/impl/FaissAssert.h>
+#include <faiss/invlists/DirectMap.h>
+#include <faiss/invlists/InvertedLists.h>
+
+using faiss::InvertedLists;
+
+namespace {
+
+faiss::IndexIVF* clone(const faiss::IndexIVF* ivf) {
+    return dynamic_cast<faiss::IndexIVF*>(faiss::clone_index(ivf));
+}
+
+// Returns an empty copy of ivf. Cloner::clone_IndexIVF copy-constructs ivf
+// as its concrete type, sharing its inverted lists and quantizer, which are
+// replaced before anything can throw, so that the codes are not copied and
+// ivf is left untouched for concurrent searches.
+faiss::IndexIVF* empty_clone(const faiss::IndexIVF* ivf) {
+    auto copy = faiss::Cloner().clone_IndexIVF(ivf);
+    FAISS_THROW_IF_NOT_MSG(copy, "cannot clone this type of index");
+    copy->own_fields = false;
+    copy->own_invlists = false;
+    copy->invlists = nullptr;
+    std::unique_ptr<faiss::IndexIVF> rv(copy);
+    rv->direct_map = faiss::DirectMap();
+    rv->ntotal = 0;
+    rv->invlists = new faiss::ArrayInvertedLists(
+            ivf->nlist, ivf->invlists->code_size);
+    rv->own_invlists = true;
+    rv->quantizer = faiss::clone_index(ivf->quantizer);
+    rv->own_fields = true;
+    return rv.release();
+}
+
+// IndexBinaryIVF being the only binary IVF class, it is copied directly, like
+// the float indexes above.
+faiss::IndexBinaryIVF* empty_clone(const faiss::IndexBinaryIVF* ivf) {
+    auto copy = new faiss::IndexBinaryIVF(*ivf);
+    copy->own_fields = false;
+    copy->own_invlists = false;
+    copy->invlists = nullptr;
+    std::unique_ptr<faiss::IndexBinaryIVF> rv(copy);
+    rv->direct_map = faiss::DirectMap();
+    rv->ntotal = 0;
+    rv->invlists = new faiss::ArrayInvertedLists(
+            ivf->nlist, ivf->invlists->code_size);
+    rv->own_invlists = true;
+    rv->quantizer = faiss::clone_binary_index(ivf->quantizer);
+    rv->own_fields = true;
+    return rv.release();
+}
+
+faiss::IndexBinaryIVF* clone(const faiss::IndexBinaryIVF* ivf) {
+    return empty_clone(ivf);
+}
+
+faiss::IndexIVF* ivf_cast(FaissIndex* index) {
+    return dynamic_cast<faiss::IndexIVF*>(
+            reinterpret_cast<faiss::Index*>(index));
+}
+
+faiss::IndexBinaryIVF* ivf_cast(FaissIndexBinary* index) {
+    return dynamic_cast<faiss::IndexBinaryIVF*>(
+            reinterpret_cast<faiss::IndexBinary*>(index));
+}
+
+FaissIndex* c_index(faiss::IndexIVF* ivf) {
+    return reinterpret_cast<FaissIndex*>(static_cast<faiss::Index*>(ivf));
+}
+
+FaissIndexBinary* c_index(faiss::IndexBinaryIVF* ivf) {
+    return reinterpret_cast<FaissIndexBinary*>(
+            static_cast<faiss::IndexBinary*>(ivf));
+}
+
+template <typename C>
+void split(
+        C* index,
+        size_t nshards,
+        const int* list_shard,
+        size_t nboundaries,
+        const idx_t* boundaries,
+        C** shards) {
+    auto ivf = ivf_cast(index);
+    using IVF = typename std::remove_pointer<decltype(ivf)>::type;
+    FAISS_THROW_IF_NOT_MSG(ivf, "not an IVF index");
+    const InvertedLists* il = ivf->invlists;
+    size_t code_size = il->code_size;
+    FAISS_THROW_IF_NOT_MSG(
+            code_size != InvertedLists::INVALID_CODE_SIZE,
+            "the inverted lists do not store one code per entry");
+
+    std::vector<std::unique_ptr<IVF>> rv(nshards);
+    {
+        std::unique_ptr<IVF> empty(empty_clone(ivf));
+        FAISS_THROW_IF_NOT_MSG(empty, "cannot clone this type of index");
+        for (auto& shard : rv) {
+            shard.reset(clone(empty.get()));
+        }
+    }
+
+    for (size_t list_no = 0; list_no < ivf->nlist; list_no++) {
+        size_t size = il->list_size(list_no);
+        if (size == 0) {
+            continue;
+        }
+        InvertedLists::ScopedIds ids(il, list_no);
+        InvertedLists::ScopedCodes codes(il, list_no);
+        for (size_t i = 0; i < size; i++) {
+            idx_t id = ids.get()[i];
+            size_t s = list_shard != nullptr
+                    ? size_t(list_shard[list_no])
+                    : size_t(std::upper_bound(
+                                     boundaries, boundaries + nboundaries, id) -
+                             boundaries);
+            FAISS_THROW_IF_NOT_FMT(
+                    s < nshards, "invalid shard for list %zd", list_no);
+            rv[s]->invlists->add_entry(
+                    list_no, id, codes.get() + i * code_size);
+            rv[s]->ntotal++;
+        }
+    }
+
+    // an array direct map requires sequential IDs, which the shards do not
+    // have in general.
+    faiss::DirectMap::Type dm_type = ivf->direct_map.type;
+    if (dm_type == faiss::DirectMap::Array) {
+        dm_type = faiss::DirectMap::Hashtable;
+    }
+    for (auto& shard : rv) {
+        shard->set_direct_map_type(dm_type);
+    }
+    for (size_t s = 0; s < nshards; s++) {
+        shards[s] = c_index(rv[s].release());
+    }
+}
+
+} // namespace
+
+int gofaiss_IndexIVF_split(
+        FaissIndex* index,
+        size_t nshards,
+        const int* list_shard,
+        size_t nboundaries,
+        const idx_t* boundaries,
+        FaissIndex** shards) {
+    GOFAISS_TRY
+    split(
+            index, nshards, list_shard, nboundaries, boundaries, shards);
+    GOFAISS_CATCH
+}
+
+int gofaiss_IndexBinaryIVF_split(
+        FaissIndexBinary* index,
+        size_t nshards,
+        const int* list_shard,
+        size_t nboundaries,
+        const idx_t* boundaries,
+        FaissIndexBinary** shards) {
+    GOFAISS_TRY
+    split(
+            index, nshards, list_shard, nboundaries, boundaries, shards);
+    GOFAISS_CATCH
+}
@@ -0,0 +1,190 @@
+package faiss
+
+/*
+#include <faiss/c_api/IndexBinaryIVF_c.h>
+#include <faiss/c_api/IndexIVF_c.h>
+#include "gofaiss.h"
+*/
+import "C"
+import (
+	"fmt"
+	"runtime"
+)
+
+// The Split methods are the inverse of MergeFrom: the shards are IVF indexes
+// of the same type as the index they come from, with copies of its trained
+// quantizer, so that they can be searched like it and merged back. An array
+// direct map, which requires sequential IDs, becomes a hash table direct map
+// in the shards.
+//
+// The index is only read, so it can be searched concurrently with a split.
+// Its codes are copied into the shards only, and each shard gets its own
+// copy of the quantizer, which therefore takes nshards times its memory.
+
+// splitBoundaries checks that boundaries are strictly increasing, and returns
+// the number of shards they delimit.
+func splitBoundaries(boundaries []int64) (int, error) {
+	if len(boundaries) == 0 {
+		return 0, ErrEmptyInput
+	}
+	for i := 1; i < len(boundaries); i++ {
+		if boundaries[i] <= boundaries[i-1] {
+			return 0, fmt.Errorf("%w: boundaries must be strictly increasing",
+				ErrSplitFailed)
+		}
+	}
+	return len(boundaries) + 1, nil
+}
+
+// splitListShards returns the shard of each of the nlist lists, checking that
+// every list belongs to exactly one group.
+func splitListShards(groups [][]int64, nlist int) ([]C.int, error) {
+	if len(groups) == 0 {
+		return nil, ErrEmptyInput
+	}
+	listShard := make([]C.int, nlist)
+	for i := range listShard {
+		listShard[i] = -1
+	}
+	for shard, group := range groups {
+		for _, list := range group {
+			if list < 0 || list >= int64(nlist) {
+				return nil, fmt.Errorf("%w: list %d, nlist=%d", ErrInvalidList,
+					list, nlist)
+			}
+			if listShard[list] >= 0 {
+				return nil, fmt.Errorf("%w: list %d is in groups %d and %d",
+					ErrSplitFailed, list, listShard[list], shard)
+			}
+			listShard[list] = C.int(shard)
+		}
+	}
+	for list, shard := range listShard {
+		if shard < 0 {
+			return nil, fmt.Errorf("%w: list %d is in no group", ErrSplitFailed, list)
+		}
+	}
+	return listShard, nil
+}
+
+func (idx *faissIndex) SplitByIDRange(boundaries []int64) ([]Index, error) {
+	if idx.closed() {
+		return nil, ErrIndexClosed
+	}
+	defer runtime.KeepAlive(idx)
+	if C.faiss_IndexIVF_cast(idx.idx) == nil {
+		return nil, ErrNotIVFIndex
+	}
+	nshards, err := splitBoundaries(boundaries)
+	if err != nil {
+		return nil, err
+	}
+	return idx.split(nshards, nil, boundaries)
+}
+
+func (idx *faissIndex) SplitByLists(groups [][]int64) ([]Index, error) {
+	if idx.closed() {
+		return nil, ErrIndexClosed
+	}
+	defer runtime.KeepAlive(idx)
+	ivfPtr := C.faiss_IndexIVF_cast(idx.idx)
+	if ivfPtr == nil {
+		return nil, ErrNotIVFIndex
+	}
+	listShard, err := splitListShards(groups, int(C.faiss_IndexIVF_nlist(ivfPtr)))
+	if err != nil {
+		return nil, err
+	}
+	return idx.split(len(groups), listShard, nil)
+}
+
+// split runs gofaiss_IndexIVF_split with either listShard or boundaries.
+func (idx *faissIndex) split(nshards int, listShard []C.int,
+	boundaries []int64) ([]Index, error) {
+	var listShardPtr *C.int
+	if len(listShard) > 0 {
+		listShardPtr = &listShard[0]
+	}
+	var boundariesPtr *C.idx_t
+	if len(boundaries) > 0 {
+		boundariesPtr = (*C.idx_t)(&boundaries[0])
+	}
+	shards := make([]*C.FaissIndex, nshards)
+	if c := C.gofaiss_IndexIVF_split(
+		idx.idx,
+		C.size_t(nshards),
+		listShardPtr,
+		C.size_t(len(boundaries)),
+		boundariesPtr,
+		&shards[0],
+	); c != 0 {
+		return nil, newFaissError(ErrSplitFailed, getLastError(), int(c))
+	}
+	rv := make([]Index, nshards)
+	for i, shard := range shards {
+		rv[i] = &IndexImpl{newFaissIndex(shard)}
+	}
+	return rv, nil
+}
+
+func (b *faissBinaryIndex) SplitByIDRange(boundaries []int64) ([]BinaryIndex, error) {
+	if b.closed() {
+		return nil, ErrIndexClosed
+	}
+	defer runtime.KeepAlive(b)
+	if C.faiss_IndexBinaryIVF_cast(b.bIdx) == nil {
+		return nil, ErrNotBIVFIndex
+	}
+	nshards, err := splitBoundaries(boundaries)
+	if err != nil {
+		return nil, err
+	}
+	return b.split(nshards, nil, boundaries)
+}
+
+func (b *faissBinaryIndex) SplitByLists(groups [][]int64) ([]BinaryIndex, error) {
+	if b.closed() {
+		return nil, ErrIndexClosed
+	}
+	defer runtime.KeepAlive(b)
+	ivfPtr := C.faiss_IndexBinaryIVF_cast(b.bIdx)
+	if ivfPtr == nil {
+		return nil, ErrNotBIVFIndex
+	}
+	listShard, err := splitListShards(groups,
+		int(C.faiss_IndexBinaryIVF_nlist(ivfPtr)))
+	if err != nil {
+		return nil, err
+	}
+	return b.split(len(groups), listShard, nil)
+}
+
+// split runs gofaiss_IndexBinaryIVF_split with either listShard or
+// boundaries.
+func (b *faissBinaryIndex) split(nshards int, listShard []C.int,
+	boundaries []int64) ([]BinaryIndex, error) {
+	var listShardPtr *C.int
+	if len(listShard) > 0 {
+		listShardPtr = &listShard[0]
+	}
+	var boundariesPtr *C.idx_t
+	if len(boundaries) > 0 {
+		boundariesPtr = (*C.idx_t)(&boundaries[0])
+	}
+	shards := make([]*C.FaissIndexBinary, nshards)
+	if c := C.gofaiss_IndexBinaryIVF_split(
+		b.bIdx,
+		C.size_t(nshards),
+		listShardPtr,
+		C.size_t(len(boundaries)),
+		boundariesPtr,
+		&shards[0],
+	); c != 0 {
+		return nil, newFaissError(ErrSplitFailed, getLastError(), int(c))
+	}
+	rv := make([]BinaryIndex, nshards)
+	for i, shard := range shards {
+		rv[i] = &BinaryIndexImpl{newFaissBinaryIndex(shard)}
+	}
+	return rv, nil
+}
@@ -0,0 +1,42 @@
+// Vector transforms, see transform.go.
+
+#include "gofaiss.h"
+#include "gofaiss_impl.h"
+
+#include <faiss/IndexPreTransform.h>
+#include <faiss/VectorTransform.h>
+
+int gofaiss_VectorTransform_apply(
+        const FaissVectorTransform* vt,
+        idx_t n,
+        const float* x,
+        float* xt) {
+    GOFAISS_TRY
+    reinterpret_cast<const faiss::VectorTransform*>(vt)->apply_noalloc(
+            n, x, xt);
+    GOFAISS_CATCH
+}
+
+int gofaiss_VectorTransform_reverse_transform(
+        const FaissVectorTransform* vt,
+        idx_t n,
+        const float* xt,
+        float* x) {
+    GOFAISS_TRY
+    reinterpret_cast<const faiss::VectorTransform*>(vt)->reverse_transform(
+            n, xt, x);
+    GOFAISS_CATCH
+}
+
+int gofaiss_IndexPreTransform_new(
+        FaissIndex** p_index,
+        FaissVectorTransform* vt,
+        FaissIndex* index) {
+    GOFAISS_TRY
+    auto* rv = new faiss::IndexPreTransform(
+            reinterpret_cast<faiss::VectorTransform*>(vt),
+            reinterpret_cast<faiss::Index*>(index));
+    rv->own_fields = true;
+    *p_index = reinterpret_cast<FaissIndex*>(rv);
+    GOFAISS_CATCH
+}
@@ -0,0 +1,344 @@
+package faiss
+
+/*
+#include "gofaiss.h"
+*/
+import "C"
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"runtime"
+)
+
+// VectorTransform is a faiss vector transform, mapping vectors of dimension
+// DIn to vectors of dimension DOut, such as a dimensionality reduction. It can
+// be used standalone, or applied to the vectors of an index with
+// NewIndexPreTransform.
+type VectorTransform interface {
+	// DIn returns the dimension of the input vectors.
+	DIn() int
+
+	// DOut returns the dimension of the output vectors.
+	DOut() int
+
+	// IsTrained returns true if the transform has been trained or does not
+	// require training.
+	IsTrained() bool
+
+	// Train trains the transform on a representative set of vectors.
+	Train(x []float32) error
+
+	// Apply transforms the vectors in x.
+	Apply(x []float32) ([]float32, error)
+
+	// ReverseTransform maps the transformed vectors in xt back to the input
+	// space. This is exact for orthogonal transforms only, and not supported
+	// by all transforms.
+	ReverseTransform(xt []float32) ([]float32, error)
+
+	// Close frees the memory used by the transform. It is safe to call Close
+	// more than once; any other method called after Close returns
+	// ErrTransformClosed (or a zero value, for methods that cannot return an
+	// error).
+	Close()
+
+	// vtPtr returns a pointer to the underlying C transform struct.
+	vtPtr() *C.FaissVectorTransform
+
+	// impl returns the underlying faissVectorTransform.
+	impl() *faissVectorTransform
+}
+
+type faissVectorTransform struct {
+	vt *C.FaissVectorTransform
+
+	// cleanup frees vt if the transform is garbage collected without Close.
+	cleanup runtime.Cleanup
+
+	// parent is set once vt is owned by an index, see NewIndexPreTransform,
+	// in which case vt is never freed here.
+	parent *faissIndex
+}
+
+// newFaissVectorTransform wraps a transform allocated on the C heap, taking
+// ownership of it.
+func newFaissVectorTransform(ptr *C.FaissVectorTransform) *faissVectorTransform {
+	vt := &faissVectorTransform{vt: ptr}
+	vt.cleanup = addLeakCleanup(vt, "faiss vector transform", func() {
+		C.faiss_VectorTransform_free(ptr)
+	})
+	return vt
+}
+
+// closed returns true if the transform, or the index owning it, has been
+// closed.
+func (vt *faissVectorTransform) closed() bool {
+	return vt.vt == nil || (vt.parent != nil && vt.parent.closed())
+}
+
+// vtPtr returns nil once the transform has been closed.
+func (vt *faissVectorTransform) vtPtr() *C.FaissVectorTransform {
+	if vt.closed() {
+		return nil
+	}
+	return vt.vt
+}
+
+func (vt *faissVectorTransform) impl() *faissVectorTransform {
+	return vt
+}
+
+func (vt *faissVectorTransform) DIn() int {
+	if vt.closed() {
+		return 0
+	}
+	defer runtime.KeepAlive(vt)
+	return int(C.faiss_VectorTransform_d_in(vt.vt))
+}
+
+func (vt *faissVectorTransform) DOut() int {
+	if vt.closed() {
+		return 0
+	}
+	defer runtime.KeepAlive(vt)
+	return int(C.faiss_VectorTransform_d_out(vt.vt))
+}
+
+func (vt *faissVectorTransform) IsTrained() bool {
+	if vt.closed() {
+		return false
+	}
+	defer runtime.KeepAlive(vt)
+	return C.faiss_VectorTransform_is_trained(vt.vt) != 0
+}
+
+func (vt *faissVectorTransform) Train(x []float32) error {
+	if vt.closed() {
+		return ErrTransformClosed
+	}
+	defer runtime.KeepAlive(vt)
+	n, err := validateVectors(x, vt.DIn())
+	if err != nil {
+		return err
+	}
+	if c := C.faiss_VectorTransform_train(vt.vt, C.idx_t(n),
+		(*C.float)(&x[0])); c != 0 {
+		return newFaissError(ErrTrainFailed, getLastError(), int(c))
+	}
+	return nil
+}
+
+func (vt *faissVectorTransform) Apply(x []float32) ([]float32, error) {
+	if vt.closed() {
+		return nil, ErrTransformClosed
+	}
+	defer runtime.KeepAlive(vt)
+	n, err := validateVectors(x, vt.DIn())
+	if err != nil {
+		return nil, err
+	}
+	xt := make([]float32, n*vt.DOut())
+	if c := C.gofaiss_VectorTransform_apply(vt.vt, C.idx_t(n),
+		(*C.float)(&x[0]), (*C.float)(&xt[0])); c != 0 {
+		return nil, newFaissError(ErrApplyTransformFailed, getLastError(), int(c))
+	}
+	return xt, nil
+}
+
+func (vt *faissVectorTransform) ReverseTransform(xt []float32) ([]float32, error) {
+	if vt.closed() {
+		return nil, ErrTransformClosed
+	}
+	defer runtime.KeepAlive(vt)
+	n, err := validateVectors(xt, vt.DOut())
+	if err != nil {
+		return nil, err
+	}
+	x := make([]float32, n*vt.DIn())
+	if c := C.gofaiss_VectorTransform_reverse_transform(vt.vt, C.idx_t(n),
+		(*C.float)(&xt[0]), (*C.float)(&x[0])); c != 0 {
+		return nil, newFaissError(ErrApplyTransformFailed, getLastError(), int(c))
+	}
+	return x, nil
+}
+
+func (vt *faissVectorTransform) Close() {
+	if vt.vt == nil {
+		return
+	}
+	if vt.parent == nil {
+		vt.cleanup.Stop()
+		C.faiss_VectorTransform_free(vt.vt)
+	}
+	vt.vt = nil
+}
+
+// VectorTransformImpl is a vector transform of any type, as returned by
+// ReadVectorTransformFrom and ReadVectorTransformFromBuffer.
+type VectorTransformImpl struct {
+	VectorTransform
+}
+
+// PCAMatrix reduces the dimension of vectors to their dOut principal
+// components, learned by Train.
+type PCAMatrix struct {
+	VectorTransform
+}
+
+// NewPCAMatrix creates a new PCA transform. The components are multiplied by
+// their eigenvalue to the power eigenPower, -0.5 whitening the output, and
+// are randomly rotated if randomRotation is set, which balances their
+// variance.
+func NewPCAMatrix(dIn, dOut int, eigenPower float32, randomRotation bool) (
+	*PCAMatrix, error) {
+	var vt *C.FaissPCAMatrix
+	if c := C.faiss_PCAMatrix_new_with(&vt, C.int(dIn), C.int(dOut),
+		C.float(eigenPower), boolToCInt(randomRotation)); c != 0 {
+		return nil, newFaissError(ErrCreateTransformFailed, getLastError(), int(c))
+	}
+	return &PCAMatrix{newFaissVectorTransform((*C.FaissVectorTransform)(vt))}, nil
+}
+
+// OPQMatrix rotates vectors so that they are better encoded by a product
+// quantizer with M sub-quantizers, learned by Train.
+type OPQMatrix struct {
+	VectorTransform
+}
+
+// NewOPQMatrix creates a new OPQ transform of vectors of dimension d into
+// vectors of dimension d2, which must be a multiple of M. A d2 of -1 keeps
+// the dimension.
+func NewOPQMatrix(d, M, d2 int) (*OPQMatrix, error) {
+	var vt *C.FaissOPQMatrix
+	if c := C.faiss_OPQMatrix_new_with(&vt, C.int(d), C.int(M),
+		C.int(d2)); c != 0 {
+		return nil, newFaissError(ErrCreateTransformFailed, getLastError(), int(c))
+	}
+	return &OPQMatrix{newFaissVectorTransform((*C.FaissVectorTransform)(vt))}, nil
+}
+
+// RandomRotationMatrix applies a random orthogonal transform, drawn by Train
+// which does not use the training vectors.
+type RandomRotationMatrix struct {
+	VectorTransform
+}
+
+// NewRandomRotationMatrix creates a new random rotation.
+func NewRandomRotationMatrix(dIn, dOut int) (*RandomRotationMatrix, error) {
+	var vt *C.FaissRandomRotationMatrix
+	if c := C.faiss_RandomRotationMatrix_new_with(&vt, C.int(dIn),
+		C.int(dOut)); c != 0 {
+		return nil, newFaissError(ErrCreateTransformFailed, getLastError(), int(c))
+	}
+	return &RandomRotationMatrix{
+		newFaissVectorTransform((*C.FaissVectorTransform)(vt))}, nil
+}
+
+// ITQTransform rotates vectors so as to minimize the quantization error of
+// their binarization, as learned by Train, for use with binary indexes. It
+// does not support ReverseTransform.
+type ITQTransform struct {
+	VectorTransform
+}
+
+// NewITQTransform creates a new ITQ transform, reducing the dimension of the
+// vectors with a PCA first if doPCA is set.
+func NewITQTransform(dIn, dOut int, doPCA bool) (*ITQTransform, error) {
+	var vt *C.FaissITQTransform
+	if c := C.faiss_ITQTransform_new_with(&vt, C.int(dIn), C.int(dOut),
+		boolToCInt(doPCA)); c != 0 {
+		return nil, newFaissError(ErrCreateTransformFailed, getLastError(), int(c))
+	}
+	return &ITQTransform{newFaissVectorTransform((*C.FaissVectorTransform)(vt))}, nil
+}
+
+// WriteVectorTransformTo serializes a transform into w, like WriteIndexTo.
+func WriteVectorTransformTo(vt VectorTransform, w io.Writer) error {
+	ptr := vt.vtPtr()
+	if ptr == nil {
+		return ErrTransformClosed
+	}
+	defer runtime.KeepAlive(vt)
+	s := &indexStream{w: w}
+	return s.run(ErrWriteIndexFailed, func(handle C.uintptr_t) C.int {
+		return C.gofaiss_write_VectorTransform_stream(ptr, handle)
+	})
+}
+
+// ReadVectorTransformFrom deserializes a transform from r, like
+// ReadIndexFrom.
+func ReadVectorTransformFrom(r io.Reader) (*VectorTransformImpl, error) {
+	var vt *C.FaissVectorTransform
+	s := &indexStream{r: r}
+	if err := s.run(ErrReadIndexFailed, func(handle C.uintptr_t) C.int {
+		return C.gofaiss_read_VectorTransform_stream(handle, &vt)
+	}); err != nil {
+		return nil, err
+	}
+	return &VectorTransformImpl{newFaissVectorTransform(vt)}, nil
+}
+
+// WriteVectorTransformIntoBuffer serializes a transform into a buffer.
+func WriteVectorTransformIntoBuffer(vt VectorTransform) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := WriteVectorTransformTo(vt, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ReadVectorTransformFromBuffer deserializes a transform written by
+// WriteVectorTransformIntoBuffer.
+func ReadVectorTransformFromBuffer(buf []byte) (*VectorTransformImpl, error) {
+	return ReadVectorTransformFrom(bytes.NewReader(buf))
+}
+
+// IndexPreTransform is an index which applies a vector transform to all the
+// vectors it is given, for training, adding or searching, before handing them
+// to the index it wraps.
+type IndexPreTransform struct {
+	Index
+}
+
+// NewIndexPreTransform creates an index applying transform before index, so
+// that the dimension of the index is transform.DIn(), and that of index must
+// be transform.DOut(). Training it trains transform, then index on the
+// transformed vectors, unless they are already trained.
+//
+// The returned index takes ownership of transform and index: they are freed
+// along with it, and closing them beforehand does nothing but make them
+// unusable. They can therefore not be given to another IndexPreTransform.
+func NewIndexPreTransform(transform VectorTransform, index Index) (
+	*IndexPreTransform, error) {
+	if transform == nil || index == nil {
+		return nil, ErrIndexNil
+	}
+	vt, sub := transform.impl(), index.impl()
+	if vt.closed() {
+		return nil, ErrTransformClosed
+	}
+	if err := sub.writable(); err != nil {
+		return nil, err
+	}
+	if vt.parent != nil {
+		return nil, fmt.Errorf("%w: transform", ErrAlreadyOwned)
+	}
+	if sub.parent != nil {
+		return nil, fmt.Errorf("%w: index", ErrAlreadyOwned)
+	}
+	if vt.DOut() != sub.D() {
+		return nil, fmt.Errorf("%w: transform outputs d=%d, index has d=%d",
+			ErrDimensionMismatch, vt.DOut(), sub.D())
+	}
+	var idx *C.FaissIndex
+	if c := C.gofaiss_IndexPreTransform_new(&idx, vt.vt, sub.idx); c != 0 {
+		return nil, newFaissError(ErrCreateIndexFailed, getLastError(), int(c))
+	}
+	rv := newFaissIndex(idx)
+	vt.cleanup.Stop()
+	vt.parent = rv
+	sub.cleanup.Stop()
+	sub.parent = rv
+	return &IndexPreTransform{rv}, nil
+}
@@ -0,0 +1,163 @@
+// In-place vector updates, see update.go.
+
+#include "gofaiss.h"
+#include "gofaiss_impl.h"
+
+#include <cinttypes>
+#include <unordered_set>
+#include <vector>
+
+#include <faiss/IndexIDMap.h>
+#include <faiss/IndexIVF.h>
+#include <faiss/impl/FaissAssert.h>
+#include <faiss/impl/IDSelector.h>
+#include <faiss/invlists/DirectMap.h>
+
+namespace {
+
+void check_unique(idx_t n, const idx_t* ids) {
+    std::unordered_set<idx_t> seen(ids, ids + n);
+    FAISS_THROW_IF_NOT_MSG(seen.size() == size_t(n), "duplicate IDs");
+}
+
+// IndexIVF::update_vectors requires all the IDs to be in the index already,
+// so the IDs are split into those it updates and those which are added.
+size_t update_ivf(
+        faiss::IndexIVF* ivf,
+        idx_t n,
+        const idx_t* ids,
+        const float* x) {
+    const faiss::DirectMap& dm = ivf->direct_map;
+    FAISS_THROW_IF_NOT_MSG(
+            dm.type != faiss::DirectMap::NoMap,
+            "updating vectors requires a direct map");
+    size_t d = ivf->d;
+    std::vector<idx_t> upd_ids, new_ids;
+    std::vector<float> upd_x, new_x;
+    for (idx_t i = 0; i < n; i++) {
+        idx_t list_no, offset;
+        gofaiss_DirectMap_lookup(
+                reinterpret_cast<const GofaissDirectMap*>(&dm),
+                ids[i],
+                &list_no,
+                &offset);
+        bool exists = list_no >= 0;
+        (exists ? upd_ids : new_ids).push_back(ids[i]);
+        auto& dst = exists ? upd_x : new_x;
+        dst.insert(dst.end(), x + i * d, x + (i + 1) * d);
+    }
+    // an array direct map only grows with the sequential IDs of add.
+    if (dm.type == faiss::DirectMap::Array) {
+        for (size_t i = 0; i < new_ids.size(); i++) {
+            FAISS_THROW_IF_NOT_FMT(
+                    new_ids[i] == ivf->ntotal + idx_t(i),
+                    "ID %" PRId64
+                    " is not in the index, and an array direct map only "
+                    "allows adding sequential IDs",
+                    new_ids[i]);
+        }
+    }
+    if (!upd_ids.empty()) {
+        ivf->update_vectors(int(upd_ids.size()), upd_ids.data(), upd_x.data());
+    }
+    if (!new_ids.empty()) {
+        if (dm.type == faiss::DirectMap::Array) {
+            ivf->add(new_ids.size(), new_x.data());
+        } else {
+            ivf->add_with_ids(new_ids.size(), new_x.data(), new_ids.data());
+        }
+    }
+    return upd_ids.size();
+}
+
+// An IVF index below an IDMap2 index stores the internal IDs the IDMap2 index
+// assigned, which the IDs in the index are translated to for update_vectors.
+// Removing them instead would fail with an array direct map, which does not
+// support removals, and with a hashtable one leave the IDMap2 index, which
+// compacts its internal IDs on removal, out of step with the IVF index, which
+// keeps them.
+size_t update_idmap2_ivf(
+        faiss::IndexIDMap2* idmap,
+        faiss::IndexIVF* ivf,
+        idx_t n,
+        const idx_t* ids,
+        const float* x) {
+    size_t d = idmap->d;
+    std::vector<idx_t> upd_ids, new_ids;
+    std::vector<float> upd_x, new_x;
+    for (idx_t i = 0; i < n; i++) {
+        auto it = idmap->rev_map.find(ids[i]);
+        bool exists = it != idmap->rev_map.end();
+        if (exists) {
+            upd_ids.push_back(it->second);
+        } else {
+            new_ids.push_back(ids[i]);
+        }
+        auto& dst = exists ? upd_x : new_x;
+        dst.insert(dst.end(), x + i * d, x + (i + 1) * d);
+    }
+    if (!upd_ids.empty()) {
+        ivf->update_vectors(int(upd_ids.size()), upd_ids.data(), upd_x.data());
+    }
+    if (!new_ids.empty()) {
+        idmap->add_with_ids(new_ids.size(), new_x.data(), new_ids.data());
+    }
+    return upd_ids.size();
+}
+
+// The vectors being replaced are kept until the new ones are added, so that
+// a failure leaves the index unchanged.
+size_t update_idmap2(
+        faiss::IndexIDMap2* idmap,
+        idx_t n,
+        const idx_t* ids,
+        const float* x) {
+    if (auto ivf = dynamic_cast<faiss::IndexIVF*>(idmap->index)) {
+        return update_idmap2_ivf(idmap, ivf, n, ids, x);
+    }
+    size_t d = idmap->d;
+    std::vector<idx_t> old_ids;
+    for (idx_t i = 0; i < n; i++) {
+        if (idmap->rev_map.count(ids[i]) > 0) {
+            old_ids.push_back(ids[i]);
+        }
+    }
+    std::vector<float> old_x(old_ids.size() * d);
+    for (size_t i = 0; i < old_ids.size(); i++) {
+        idmap->reconstruct(old_ids[i], old_x.data() + i * d);
+    }
+    if (!old_ids.empty()) {
+        faiss::IDSelectorBatch sel(old_ids.size(), old_ids.data());
+        idmap->remove_ids(sel);
+    }
+    try {
+        idmap->add_with_ids(n, x, ids);
+    } catch (...) {
+        if (!old_ids.empty()) {
+            idmap->add_with_ids(old_ids.size(), old_x.data(), old_ids.data());
+        }
+        throw;
+    }
+    return old_ids.size();
+}
+
+} // namespace
+
+int gofaiss_Index_update_vectors(
+        FaissIndex* index,
+        idx_t n,
+        const idx_t* ids,
+        const float* x,
+        size_t* n_updated) {
+    GOFAISS_TRY
+    check_unique(n, ids);
+    faiss::Index* findex = reinterpret_cast<faiss::Index*>(index);
+    if (auto idmap = dynamic_cast<faiss::IndexIDMap2*>(findex)) {
+        *n_updated = update_idmap2(idmap, n, ids, x);
+    } else if (auto ivf = dynamic_cast<faiss::IndexIVF*>(findex)) {
+        *n_updated = update_ivf(ivf, n, ids, x);
+    } else {
+        FAISS_THROW_MSG("updating vectors requires an IDMap2 or IVF index");
+    }
+    GOFAISS_CATCH
+}
@@ -0,0 +1,56 @@
+package faiss
+
+/*
+#include <faiss/c_api/IndexIVF_c.h>
+#include <faiss/c_api/MetaIndexes_c.h>
+#include "gofaiss.h"
+*/
+import "C"
+import "runtime"
+
+// UpdateVectors is a single cgo call, so that the vectors being replaced are
+// never missing from the index, as they would be between a RemoveIDs and an
+// AddWithIDs. IVF indexes, including below an IDMap2 index, update the
+// vectors in place through their direct map, while other IDMap2 indexes
+// remove and add them again. If it fails, the index is left unchanged,
+// except for failures of faiss itself halfway through an IVF update, and for
+// IDMap2 indexes wrapping a lossy index such as SQ or PQ, to which the
+// replaced vectors are restored as reconstructed from their codes.
+func (idx *faissIndex) UpdateVectors(ids []int64, x []float32) (
+	updated, inserted int, err error) {
+	if err := idx.writable(); err != nil {
+		return 0, 0, err
+	}
+	defer runtime.KeepAlive(idx)
+	n, err := validateVectors(x, idx.D())
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := validateIDs(ids, n); err != nil {
+		return 0, 0, err
+	}
+	// IVF indexes, including below an IDMap2 index, need a direct map to
+	// locate the vectors they update.
+	ivf := idx.idx
+	if idMap := C.faiss_IndexIDMap2_cast(idx.idx); idMap != nil {
+		ivf = C.faiss_IndexIDMap2_sub_index(idMap)
+	}
+	if dm := C.gofaiss_IndexIVF_direct_map(ivf); dm != nil {
+		if C.gofaiss_DirectMap_type(dm) == 0 {
+			return 0, 0, ErrNoDirectMap
+		}
+	} else if ivf == idx.idx {
+		return 0, 0, ErrUpdateNotSupported
+	}
+	var nUpdated C.size_t
+	if c := C.gofaiss_Index_update_vectors(
+		idx.idx,
+		C.idx_t(n),
+		(*C.idx_t)(&ids[0]),
+		(*C.float)(&x[0]),
+		&nUpdated,
+	); c != 0 {
+		return 0, 0, newFaissError(ErrUpdateVectorsFailed, getLastError(), int(c))
+	}
+	return int(nUpdated), n - int(nUpdated), nil
+}
@@ -0,0 +1,88 @@
+package faiss
+
+import "fmt"
+
+// The helpers below validate caller supplied slices before they are handed
+// across the cgo boundary, where an empty slice would panic on &x[0] and a
+// slice of the wrong length would silently read (or write) out of bounds or
+// drop trailing values.
+
+// validateVectors checks that x holds a non-zero whole number of
+// d-dimensional vectors and returns that number.
+func validateVectors(x []float32, d int) (int, error) {
+	if len(x) == 0 {
+		return 0, ErrEmptyInput
+	}
+	if d <= 0 || len(x)%d != 0 {
+		return 0, fmt.Errorf("%w: len(x)=%d, d=%d", ErrDimensionMismatch, len(x), d)
+	}
+	return len(x) / d, nil
+}
+
+// validateBinaryVectors checks that x holds a non-zero whole number of
+// d-bit binary vectors (d/8 bytes each) and returns that number.
+func validateBinaryVectors(x []uint8, d int) (int, error) {
+	if len(x) == 0 {
+		return 0, ErrEmptyInput
+	}
+	codeSize := d / 8
+	if codeSize <= 0 || len(x)%codeSize != 0 {
+		return 0, fmt.Errorf("%w: len(x)=%d bytes, d=%d bits", ErrDimensionMismatch, len(x), d)
+	}
+	return len(x) / codeSize, nil
+}
+
+// validateIDs checks that there is exactly one ID per vector.
+func validateIDs(ids []int64, n int) error {
+	if len(ids) != n {
+		return fmt.Errorf("%w: %d IDs for %d vectors", ErrIDCountMismatch, len(ids), n)
+	}
+	return nil
+}
+
+// validateK checks that the number of requested results is positive.
+func validateK(k int64) error {
+	if k <= 0 {
+		return fmt.Errorf("%w: k=%d", ErrInvalidK, k)
+	}
+	return nil
+}
+
+// validateBuffer checks that a caller supplied output buffer can hold the
+// required number of values.
+func validateBuffer(size, required int) error {
+	if size < required {
+		return fmt.Errorf("%w: have %d, need %d", ErrBufferTooSmall, size, required)
+	}
+	return nil
+}
+
+// validateSearchInto validates the arguments of the SearchInto family and
+// returns the number of query vectors.
+func validateSearchInto(x []float32, d int, k int64, nDistances, nLabels int) (int, error) {
+	n, err := validateVectors(x, d)
+	if err != nil {
+		return 0, err
+	}
+	return n, validateSearchBuffers(n, k, nDistances, nLabels)
+}
+
+// validateBinarySearchInto is the binary counterpart of validateSearchInto.
+func validateBinarySearchInto(x []uint8, d int, k int64, nDistances, nLabels int) (int, error) {
+	n, err := validateBinaryVectors(x, d)
+	if err != nil {
+		return 0, err
+	}
+	return n, validateSearchBuffers(n, k, nDistances, nLabels)
+}
+
+func validateSearchBuffers(n int, k int64, nDistances, nLabels int) error {
+	if err := validateK(k); err != nil {
+		return err
+	}
+	required := int(int64(n) * k)
+	if err := validateBuffer(nDistances, required); err != nil {
+		return err
+	}
+	return validateBuffer(nLabels, required)
+}